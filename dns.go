@@ -2,108 +2,153 @@ package main
 
 import (
 	"fmt"
-	"net"
+	"io"
+	"log"
+	"os"
+	"syscall"
 
 	"github.com/fossoreslp/go-dns/dns/cache"
-	"github.com/fossoreslp/go-dns/dns/error"
-	"github.com/fossoreslp/go-dns/dns/header"
-	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/config"
 	"github.com/fossoreslp/go-dns/dns/passthrough"
-	"github.com/fossoreslp/go-dns/dns/query"
-	"github.com/fossoreslp/go-dns/dns/record-names"
 	"github.com/fossoreslp/go-dns/dns/record-parser"
-	"github.com/fossoreslp/go-dns/dns/response"
+	"github.com/fossoreslp/go-dns/dns/server"
 )
 
 func main() {
-	listener, err := net.ListenUDP("udp", &net.UDPAddr{Port: 53, IP: nil})
+	cfg, check, err := config.Parse(os.Args[0], os.Args[1:], os.Getenv)
 	if err != nil {
-		panic(err)
+		fmt.Println(err.Error())
+		os.Exit(2)
 	}
-	defer listener.Close() //nolint: errcheck
-	println("Listening...")
-
-	set := parser.ParseZonesFile()
-	println("Initialization finished")
-	for {
-		var buffer [512]byte
-		rlen, remote, err := listener.ReadFromUDP(buffer[:])
-		if err != nil {
-			continue // TODO: Implement error logging
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("Invalid configuration: %s\n", err.Error())
+		os.Exit(1)
+	}
+	sources := cfg.Sources()
+	load := func() (*parser.Set, error) { return parser.Load(sources...) }
+	set, err := load()
+	if err != nil {
+		fmt.Printf("Failed to load zones: %s\n", err.Error())
+		os.Exit(1)
+	}
+	var cert *server.Certificate
+	if cfg.TLS.Enabled() {
+		if cert, err = server.LoadCertificate(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
+			fmt.Printf("Failed to load TLS certificate: %s\n", err.Error())
+			os.Exit(1)
 		}
-		req, err := message.Parse(buffer[:rlen])
+	}
+	if check {
+		fmt.Printf("Configuration OK, %d zones loaded\n", len(*set))
+		return
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.Log.File != "" {
+		f, err := os.OpenFile(cfg.Log.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
 		if err != nil {
-			continue // TODO: Implement error logging
-		}
-		var out []byte
-		if !req.Header.IsResponse() && req.Header.QuestionCount > 0 {
-			responses := make([]response.Response, 0)
-			local := false
-			for _, q := range req.Questions {
-				if q.Class != names.QCLASS(names.IN) {
-					out = dnserror.New(dnserror.NotImplemented, false).Message(req.Header.ID, q).Encode()
-					break
-				}
-				resp, dnserr := findInLocalZones(q, set, req)
-				if dnserr.IsError() {
-					out = dnserr.Message(req.Header.ID, q).Encode()
-					break
-				}
-				if resp != nil {
-					responses = append(responses, resp...)
-					local = true
-					continue
-				}
-				resp = cache.GetRecords(q.Name, q.Type)
-				if resp != nil {
-					responses = append(responses, resp...)
-					println("Cache hit")
-					continue
-				}
-				println("Cache miss")
-				resp, dnserr = passthrough.Resolve(q)
-				if dnserr.IsError() {
-					out = dnserr.Message(req.Header.ID, q).Encode()
-					break
-				}
-				cache.Cache(resp)
-				responses = append(responses, resp...)
-			}
-			if out == nil {
-				out = message.New(header.NewAnswerHeader(req.Header.ID, local, req.Header.RecursionDesired()), req.Questions, responses, nil, nil).Encode()
-			}
-		} else {
-			out = dnserror.New(dnserror.FormatError, false).Message(req.Header.ID).Encode()
+			fmt.Printf("Failed to open log file: %s\n", err.Error())
+			os.Exit(1)
 		}
+		defer f.Close() //nolint: errcheck
+		out = f
+	}
+	logger := log.New(out, "", log.LstdFlags)
 
-		_, err = listener.WriteToUDP(out, remote)
-		if err != nil {
-			fmt.Println("Failed to send response:", err.Error(), "- retrying")
-			_, err = listener.WriteToUDP(out, remote)
+	zones := server.NewZones(set)
+	zones.Minimal = cfg.MinimalResponses
+	reloader := &server.Reloader{
+		Zones: zones,
+		Load:  load,
+		Log: func(err error) {
 			if err != nil {
-				fmt.Println("Could not send response on second attempt:", err.Error(), "- giving up")
-			} else {
-				fmt.Println("Retry successful - please inspect first error message")
+				logger.Printf("Failed to reload zones: %s. Continuing with the previous zones.", err.Error())
+				return
 			}
+			logger.Println("Reloaded zones")
+		},
+	}
+	reloader.ReloadOnSignal(syscall.SIGHUP)
+	if cfg.Reload.Watch {
+		for _, s := range sources {
+			reloader.Watch(s.Path, cfg.Reload.Interval.Duration)
 		}
 	}
-}
 
-func findInLocalZones(q query.Query, set *parser.Set, req *message.Message) ([]response.Response, dnserror.Error) {
-	if set == nil {
-		return nil, dnserror.Success()
+	policy, _ := passthrough.ParsePolicy(cfg.Upstream.Policy) //nolint: errcheck
+	upstreams, _ := cfg.Upstreams()                           //nolint: errcheck
+	resolver := &passthrough.Resolver{Upstreams: upstreams, Policy: policy, UDPSize: cfg.Upstream.UDPSize, Log: logger}
+	cache.SetLimits(cfg.Cache.MaxEntries, cfg.Cache.MaxTTL)
+	steps := []server.Lookup{zones}
+	if !cfg.Cache.Disabled {
+		steps = append(steps, server.Cache())
 	}
-	e, excl := parser.Match(q.Name, set)
-	if e == nil && excl {
-		return nil, dnserror.New(dnserror.NameError, true)
+	steps = append(steps, server.Passthrough(resolver, !cfg.Cache.Disabled))
+
+	allow, _ := config.ParseNetworks(cfg.ACL.Allow) //nolint: errcheck
+	deny, _ := config.ParseNetworks(cfg.ACL.Deny)   //nolint: errcheck
+	var handler server.Handler = &server.ACL{Allow: allow, Deny: deny, Handler: server.NewChain(steps...)}
+	if cfg.Log.Queries {
+		handler = &server.QueryLog{Logger: logger, Handler: handler}
 	}
-	if e == nil && !excl {
-		return nil, dnserror.Success()
+
+	logError := func(err error) { logger.Println(err.Error()) }
+	errs := make(chan error, len(cfg.Listen)+len(cfg.TLS.Listen)+len(cfg.HTTPS.Listen))
+	for _, addr := range cfg.Listen {
+		srv := &server.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout.Duration,
+			WriteTimeout:      cfg.WriteTimeout.Duration,
+			IdleTimeout:       cfg.IdleTimeout.Duration,
+			RequestTimeout:    cfg.RequestTimeout.Duration,
+			UDPSize:           cfg.UDPSize,
+			MaxTCPConnections: cfg.MaxTCPConnections,
+			Log:               logError,
+		}
+		go func() { errs <- srv.ListenAndServe() }()
 	}
-	rs := e.GetRecordsOfType(q.Type)
-	responses := make([]response.Response, 0)
-	for _, r := range rs {
-		responses = append(responses, response.New(q.Name, r.Type(), 60, r.Encode()))
+	logger.Printf("Listening on %v", cfg.Listen)
+	if cert != nil {
+		cert.Log = func(err error) {
+			if err != nil {
+				logger.Printf("Failed to reload TLS certificate: %s. Continuing with the previous certificate.", err.Error())
+				return
+			}
+			logger.Println("Reloaded TLS certificate")
+		}
+		for _, addr := range cfg.TLS.Listen {
+			srv := &server.Server{
+				Addr:              addr,
+				Handler:           handler,
+				ReadTimeout:       cfg.ReadTimeout.Duration,
+				WriteTimeout:      cfg.WriteTimeout.Duration,
+				IdleTimeout:       cfg.IdleTimeout.Duration,
+				RequestTimeout:    cfg.RequestTimeout.Duration,
+				UDPSize:           cfg.UDPSize,
+				MaxTCPConnections: cfg.TLS.MaxConnections,
+				Log:               logError,
+			}
+			go func() { errs <- srv.ListenAndServeTLS(cert.TLSConfig()) }()
+		}
+		logger.Printf("Serving DNS over TLS on %v", cfg.TLS.Listen)
+		for _, addr := range cfg.HTTPS.Listen {
+			srv := &server.Server{
+				Addr:           addr,
+				Handler:        handler,
+				ReadTimeout:    cfg.ReadTimeout.Duration,
+				WriteTimeout:   cfg.WriteTimeout.Duration,
+				IdleTimeout:    cfg.IdleTimeout.Duration,
+				RequestTimeout: cfg.RequestTimeout.Duration,
+				UDPSize:        cfg.UDPSize,
+				HTTPSPath:      cfg.HTTPS.Path,
+				Log:            logError,
+			}
+			go func() { errs <- srv.ListenAndServeHTTPS(cert.TLSConfig()) }()
+		}
+		if len(cfg.HTTPS.Listen) > 0 {
+			logger.Printf("Serving DNS over HTTPS on %v", cfg.HTTPS.Listen)
+		}
 	}
-	return responses, dnserror.Success()
+	panic(<-errs)
 }
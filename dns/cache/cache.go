@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// shardCount is the number of independently locked parts of the cache. It has to be a power of two.
+const shardCount = 64
+
+// sweepInterval is the minimum time in seconds between two searches of the full cache for expired record sets
+const sweepInterval = 1
+
+// RecordWrapper is used to add information like TTL and cache time to a DNS record
+type RecordWrapper struct {
+	Label    label.Label
+	Record   record.Record
+	TTL      uint32
+	StoredAt int64
+}
+
+// key identifies a record set. Names are compared case-insensitively.
+type key struct {
+	name  string
+	t     names.TYPE
+	class names.CLASS
+}
+
+// set is a cached record set. Sets are never modified once stored so that readers can use them without holding a lock.
+type set struct {
+	records []RecordWrapper
+}
+
+// expired reports whether any record of the set expired at now
+func (s *set) expired(now int64) bool {
+	for _, r := range s.records {
+		if int64(r.TTL)-(now-r.StoredAt) < 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// shard is a part of the cache guarded by its own lock
+type shard struct {
+	mu   sync.RWMutex
+	sets map[key]*set
+}
+
+var shards [shardCount]shard
+
+// maxEntries and maxTTL hold the limits set by SetLimits
+var maxEntries, maxTTL int64
+
+// entries is the number of record sets in the cache
+var entries int64
+
+// lastSweep is the time of the last search for expired record sets
+var lastSweep int64
+
+func init() {
+	for i := range shards {
+		shards[i].sets = make(map[key]*set)
+	}
+}
+
+// SetLimits sets the maximum number of record sets held by the cache and the maximum TTL records are cached for.
+// Once the limit is reached, expired record sets are removed to make room. New sets are not cached while all others are still valid. Zero disables a limit.
+func SetLimits(sets int, ttl uint32) {
+	atomic.StoreInt64(&maxEntries, int64(sets))
+	atomic.StoreInt64(&maxTTL, int64(ttl))
+}
+
+func newKey(lbl label.Label, t names.TYPE, class names.CLASS) key {
+	return key{strings.ToLower(lbl.String()), t, class}
+}
+
+// shardFor returns the shard responsible for k
+func shardFor(k key) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(k.name)) //nolint: errcheck
+	return &shards[(h.Sum32()^uint32(k.t))&(shardCount-1)]
+}
+
+// get returns the record set stored for k or nil
+func get(k key) *set {
+	sh := shardFor(k)
+	sh.mu.RLock()
+	s := sh.sets[k]
+	sh.mu.RUnlock()
+	return s
+}
+
+// store replaces the record set stored for k. If the cache is full, expired sets are removed before a new set is added.
+func store(k key, s *set) {
+	if !add(k, s) {
+		sweep(time.Now().Unix())
+		add(k, s)
+	}
+}
+
+// add replaces the record set stored for k. It reports false if k is new and the cache reached its size limit.
+func add(k key, s *set) bool {
+	sh := shardFor(k)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, exists := sh.sets[k]; !exists {
+		n := atomic.AddInt64(&entries, 1)
+		if max := atomic.LoadInt64(&maxEntries); max > 0 && n > max {
+			atomic.AddInt64(&entries, -1)
+			return false
+		}
+	}
+	sh.sets[k] = s
+	return true
+}
+
+// sweep removes all record sets expired at now. The shards are locked one at a time. Sweeps closer together than sweepInterval are skipped.
+func sweep(now int64) {
+	last := atomic.LoadInt64(&lastSweep)
+	if now-last < sweepInterval || !atomic.CompareAndSwapInt64(&lastSweep, last, now) {
+		return
+	}
+	for i := range shards {
+		sh := &shards[i]
+		sh.mu.Lock()
+		for k, s := range sh.sets {
+			if s.expired(now) {
+				delete(sh.sets, k)
+				atomic.AddInt64(&entries, -1)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// remove deletes the record set stored for k if it is still s
+func remove(k key, s *set) {
+	sh := shardFor(k)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.sets[k] == s {
+		delete(sh.sets, k)
+		atomic.AddInt64(&entries, -1)
+	}
+}
+
+// GetRecords gets all records of a specific type and for a specific label
+func GetRecords(lbl label.Label, t names.QTYPE) (out []response.Response) {
+	switch t {
+	case names.AXFR, names.QTYPE_ANY: // AXFR is only supported by authoritative nameservers and ANY will be deprecated soon
+		return nil
+	case names.MAILB: // Should return MD and MF
+		return nil // These record types are not used and their implementation is therefore low priority
+	case names.MAILA: // Should return MB, MG, MR and MINFO
+		return nil // These record types are not used and their implementation is therefore low priority
+	}
+
+	k := newKey(lbl, names.TYPE(t), names.IN)
+	s := get(k)
+	if s == nil {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	if s.expired(now) {
+		remove(k, s)
+		return nil
+	}
+	for _, r := range s.records {
+		remaining := int64(r.TTL) - (now - r.StoredAt)
+		d := r.Record.Encode()
+		out = append(out, response.Response{Name: lbl, Type: r.Record.Type(), Class: names.IN, TTL: uint32(remaining), DataLength: uint16(len(d)), Data: d, Record: r.Record})
+	}
+	return
+}
+
+// Cache takes a slice of DNS responses and adds them to the cache. Records replace the cached set of the same name, type and class.
+func Cache(res []response.Response) {
+	sets := make(map[key]*set)
+	var order []key
+	limit := uint32(atomic.LoadInt64(&maxTTL))
+	now := time.Now().Unix()
+	for _, r := range res {
+		if r.Record == nil || r.Type == names.OPT {
+			continue // OPT only applies to a single message and records of unknown types cannot be encoded again
+		}
+		if limit > 0 && r.TTL > limit {
+			r.TTL = limit
+		}
+		k := newKey(r.Name, r.Type, r.Class)
+		s, ok := sets[k]
+		if !ok {
+			s = &set{}
+			sets[k] = s
+			order = append(order, k)
+		}
+		s.records = append(s.records, RecordWrapper{r.Name, r.Record, r.TTL, now})
+	}
+	for _, k := range order {
+		store(k, sets[k])
+	}
+}
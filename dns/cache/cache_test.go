@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// reset empties the cache and removes all limits
+func reset() {
+	for i := range shards {
+		shards[i].mu.Lock()
+		shards[i].sets = make(map[key]*set)
+		shards[i].mu.Unlock()
+	}
+	atomic.StoreInt64(&entries, 0)
+	atomic.StoreInt64(&lastSweep, 0)
+	SetLimits(0, 0)
+}
+
+func a(name label.Label, ttl uint32, last byte) response.Response {
+	return response.FromRecord(name, ttl, &record.A{IPv4: [4]byte{10, 0, 0, last}})
+}
+
+func TestGetRecords(t *testing.T) {
+	example := label.Label{"example", "com"}
+	tests := []struct {
+		name    string
+		cached  []response.Response
+		sets    int
+		ttl     uint32
+		lbl     label.Label
+		t       names.QTYPE
+		wantTTL []uint32
+	}{
+		{"Empty", nil, 0, 0, example, names.QTYPE(names.A), nil},
+		{"Set", []response.Response{a(example, 60, 1), a(example, 30, 2)}, 0, 0, example, names.QTYPE(names.A), []uint32{60, 30}},
+		{"Case", []response.Response{a(example, 60, 1)}, 0, 0, label.Label{"EXAMPLE", "com"}, names.QTYPE(names.A), []uint32{60}},
+		{"Other type", []response.Response{a(example, 60, 1)}, 0, 0, example, names.QTYPE(names.AAAA), nil},
+		{"Other name", []response.Response{a(example, 60, 1)}, 0, 0, label.Label{"www", "example", "com"}, names.QTYPE(names.A), nil},
+		{"Expired", []response.Response{a(example, 60, 1), a(example, 0, 2)}, 0, 0, example, names.QTYPE(names.A), nil},
+		{"ANY", []response.Response{a(example, 60, 1)}, 0, 0, example, names.QTYPE_ANY, nil},
+		{"Max TTL", []response.Response{a(example, 600, 1)}, 0, 100, example, names.QTYPE(names.A), []uint32{100}},
+		{"Max sets", []response.Response{a(label.Label{"other", "com"}, 60, 1), a(example, 60, 1)}, 1, 0, example, names.QTYPE(names.A), nil},
+		{"Mixed types", []response.Response{a(example, 60, 1), response.FromRecord(example, 60, &record.TXT{}), a(example, 90, 2)}, 0, 0, example, names.QTYPE(names.A), []uint32{60, 90}},
+		{"Unknown type", []response.Response{response.New(example, names.A, 60, []byte{10, 0, 0, 1})}, 0, 0, example, names.QTYPE(names.A), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset()
+			SetLimits(tt.sets, tt.ttl)
+			Cache(tt.cached)
+			got := GetRecords(tt.lbl, tt.t)
+			if len(got) != len(tt.wantTTL) {
+				t.Fatalf("GetRecords() = %v, want %d records", got, len(tt.wantTTL))
+			}
+			for i, r := range got {
+				if r.TTL != tt.wantTTL[i] || r.Name.String() != tt.lbl.String() || r.Record == nil {
+					t.Errorf("GetRecords()[%d] = %v, want TTL %d", i, r, tt.wantTTL[i])
+				}
+			}
+		})
+	}
+	reset()
+}
+
+func TestGetRecords_Expired(t *testing.T) {
+	reset()
+	defer reset()
+	SetLimits(1, 0)
+	Cache([]response.Response{a(label.Label{"example", "com"}, 60, 1)})
+	k := newKey(label.Label{"example", "com"}, names.A, names.IN)
+	get(k).records[0].StoredAt = time.Now().Unix() - 60
+	if got := GetRecords(label.Label{"example", "com"}, names.QTYPE(names.A)); got != nil {
+		t.Fatalf("GetRecords() = %v, want expired records to be removed", got)
+	}
+	// The expired set no longer counts towards the limit
+	Cache([]response.Response{a(label.Label{"other", "com"}, 60, 1)})
+	if got := GetRecords(label.Label{"other", "com"}, names.QTYPE(names.A)); len(got) != 1 {
+		t.Errorf("GetRecords() = %v after the expired set was removed", got)
+	}
+}
+
+func TestCache_Full(t *testing.T) {
+	reset()
+	defer reset()
+	SetLimits(2, 0)
+	Cache([]response.Response{a(label.Label{"a", "example", "com"}, 1, 1), a(label.Label{"b", "example", "com"}, 1, 2)})
+	time.Sleep(2 * time.Second)
+	Cache([]response.Response{a(label.Label{"c", "example", "com"}, 60, 3)})
+	if got := GetRecords(label.Label{"c", "example", "com"}, names.QTYPE(names.A)); len(got) != 1 {
+		t.Errorf("GetRecords() = %v, want the set cached after the others expired", got)
+	}
+	if n := atomic.LoadInt64(&entries); n != 1 {
+		t.Errorf("cache holds %d sets, want 1", n)
+	}
+}
+
+func TestCache_Replace(t *testing.T) {
+	reset()
+	defer reset()
+	SetLimits(1, 0)
+	Cache([]response.Response{a(label.Label{"example", "com"}, 60, 1), a(label.Label{"example", "com"}, 60, 2)})
+	Cache([]response.Response{a(label.Label{"Example", "com"}, 30, 3)})
+	got := GetRecords(label.Label{"example", "com"}, names.QTYPE(names.A))
+	if len(got) != 1 || got[0].TTL != 30 || got[0].Data[3] != 3 {
+		t.Errorf("GetRecords() = %v, want the set to be replaced", got)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	reset()
+	defer reset()
+	SetLimits(50, 0)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				name := label.Label{fmt.Sprintf("host%d", i%100), "example", "com"}
+				if (i+g)%2 == 0 {
+					Cache([]response.Response{a(name, uint32(i%3), byte(g))})
+				} else {
+					GetRecords(name, names.QTYPE(names.A))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	n := 0
+	for i := range shards {
+		n += len(shards[i].sets)
+	}
+	if n > 50 || int64(n) != entries {
+		t.Errorf("cache holds %d sets and counts %d, want at most 50", n, entries)
+	}
+}
+
+// benchNames are the names used by the benchmarks
+var benchNames = func() []label.Label {
+	out := make([]label.Label, 4096)
+	for i := range out {
+		out[i] = label.Label{fmt.Sprintf("host%d", i), "example", "com"}
+	}
+	return out
+}()
+
+// benchWorkers spreads the benchmark goroutines over different names
+var benchWorkers int64
+
+func benchStart() int {
+	return int(atomic.AddInt64(&benchWorkers, 1)) * 257
+}
+
+func fillCache() {
+	reset()
+	for _, n := range benchNames {
+		Cache([]response.Response{a(n, 3600, 1)})
+	}
+}
+
+func BenchmarkGetRecords(b *testing.B) {
+	fillCache()
+	defer reset()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := benchStart()
+		for pb.Next() {
+			GetRecords(benchNames[i&(len(benchNames)-1)], names.QTYPE(names.A))
+			i++
+		}
+	})
+}
+
+func BenchmarkCache(b *testing.B) {
+	fillCache()
+	defer reset()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := benchStart()
+		for pb.Next() {
+			n := benchNames[i&(len(benchNames)-1)]
+			Cache([]response.Response{a(n, 3600, byte(i))})
+			i++
+		}
+	})
+}
+
+// BenchmarkMixed runs nine lookups for every write like a cache serving mostly repeated queries
+func BenchmarkMixed(b *testing.B) {
+	fillCache()
+	defer reset()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := benchStart()
+		for pb.Next() {
+			n := benchNames[i&(len(benchNames)-1)]
+			if i%10 == 0 {
+				Cache([]response.Response{a(n, 3600, byte(i))})
+			} else {
+				GetRecords(n, names.QTYPE(names.A))
+			}
+			i++
+		}
+	})
+}
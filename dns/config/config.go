@@ -0,0 +1,380 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/passthrough"
+	"github.com/fossoreslp/go-dns/dns/record-parser"
+	"github.com/naoina/toml"
+)
+
+// DefaultFile is the configuration file read if none is specified. It is optional unlike files specified explicitly.
+const DefaultFile = "dns.toml"
+
+// Config is the configuration of the server
+type Config struct {
+	// Listen lists the addresses the server answers requests on via UDP and TCP
+	Listen []string
+	// UDPSize is the size of the buffer used for reading UDP requests and the payload size advertised via EDNS
+	UDPSize      int
+	ReadTimeout  Duration
+	WriteTimeout Duration
+	IdleTimeout  Duration
+	// RequestTimeout limits the time spent answering a single request including the queries to upstream resolvers
+	RequestTimeout    Duration
+	MaxTCPConnections int
+	// MinimalResponses disables adding authority and additional records to answers from local zones
+	MinimalResponses bool
+	Upstream         Upstream
+	TLS              TLS
+	HTTPS            HTTPS
+	Cache            Cache
+	// Zones lists the sources local zones are loaded from. If it is empty, zones.toml is loaded if it exists.
+	Zones  []Zone
+	Reload Reload
+	Log    Log
+	ACL    ACL
+}
+
+// Upstream configures the resolvers queries are passed on to
+type Upstream struct {
+	// Servers lists the upstream resolvers as host:port for plain DNS, tls://host:port#name for DNS over TLS or as URL for DNS over HTTPS.
+	// The port defaults to 853 and the name used for verifying the certificate to the host for DNS over TLS.
+	Servers []string
+	// Policy is the order the servers are tried in: failover, round-robin, random or lowest-rtt
+	Policy string
+	// UDPSize is the payload size advertised to the upstream resolvers via EDNS
+	UDPSize int
+	// Timeout is the time waited for an answer before a query is sent again
+	Timeout Duration
+	// Attempts is the number of times a query is sent to a single server before trying the next one
+	Attempts int
+	// CaseRandomization randomises the case of query names (DNS 0x20). All plain DNS servers have to preserve the case of questions in their answers.
+	CaseRandomization bool
+	// HTTPSGet sends DNS over HTTPS queries via GET instead of POST
+	HTTPSGet bool
+	TLS      UpstreamTLS
+}
+
+// UpstreamTLS configures the connections to upstream resolvers using DNS over TLS or HTTPS
+type UpstreamTLS struct {
+	// CAFile is a PEM file with the CAs certificates are verified with. The system roots are used if it is empty.
+	CAFile string
+	// Pins are base64 encoded SHA-256 digests of the public keys of the servers (RFC 7469).
+	// Without a CA file, they replace the verification of the certificate chain.
+	Pins []string
+	// IdleTimeout is the time after which an unused connection is closed
+	IdleTimeout Duration
+}
+
+// TLS configures serving DNS over TLS (RFC 7858). It is enabled by setting a certificate.
+type TLS struct {
+	// Listen lists the addresses DNS over TLS is served on
+	Listen []string
+	// CertFile and KeyFile are the PEM files of the certificate. They are reloaded when they change.
+	CertFile string
+	KeyFile  string
+	// MaxConnections limits the number of concurrent connections per address. Zero means no limit.
+	MaxConnections int
+}
+
+// Enabled reports whether DNS over TLS is served
+func (t *TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// HTTPS configures serving DNS over HTTPS (RFC 8484) and its JSON format. It uses the TLS certificate and is enabled by setting listen addresses.
+type HTTPS struct {
+	Listen []string
+	// Path is the path requests are answered on
+	Path string
+}
+
+// Cache configures the record cache
+type Cache struct {
+	Disabled bool
+	// MaxEntries limits the number of record sets in the cache. Zero means no limit.
+	MaxEntries int
+	// MaxTTL limits the time records are cached for in seconds. Zero means no limit.
+	MaxTTL uint32
+}
+
+// Zone is a source of local zones
+type Zone struct {
+	Path   string
+	Format string
+	Origin string
+}
+
+// Reload configures reloading the zones while the server is running. Zones are always reloaded on SIGHUP.
+type Reload struct {
+	// Watch enables reloading the zones when one of their files changes. Directories are only reloaded when files are added or removed.
+	Watch    bool
+	Interval Duration
+}
+
+// Log configures logging
+type Log struct {
+	// File is the file log messages are appended to. Standard output is used if it is empty.
+	File string
+	// Queries enables logging every request
+	Queries bool
+}
+
+// ACL restricts which clients may use the server. Entries are networks in CIDR notation or single IP addresses.
+type ACL struct {
+	Allow []string
+	Deny  []string
+}
+
+// Duration is a time.Duration read from strings like "10s"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration as accepted by time.ParseDuration
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Listen:         []string{":53"},
+		UDPSize:        1232,
+		IdleTimeout:    Duration{10 * time.Second},
+		RequestTimeout: Duration{5 * time.Second},
+		TLS:            TLS{Listen: []string{":853"}},
+		HTTPS:          HTTPS{Path: "/dns-query"},
+		Upstream:       Upstream{Servers: []string{passthrough.DefaultUpstream}, UDPSize: passthrough.DefaultUDPSize, Timeout: Duration{passthrough.DefaultTimeout}, Attempts: passthrough.DefaultAttempts, TLS: UpstreamTLS{IdleTimeout: Duration{passthrough.DefaultIdleTimeout}}},
+		Reload:         Reload{Interval: Duration{2 * time.Second}},
+	}
+}
+
+// ReadFile reads the configuration file at path into c. Settings missing from the file keep their current values.
+func (c *Config) ReadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint: errcheck
+	if err := toml.NewDecoder(f).Decode(c); err != nil {
+		return fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return nil
+}
+
+// Validate checks the configuration and returns all problems found
+func (c *Config) Validate() error {
+	var errs []string
+	if len(c.Listen) == 0 {
+		errs = append(errs, "no listen address")
+	}
+	for _, a := range c.Listen {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid listen address %s", a))
+		}
+	}
+	if c.UDPSize < 512 || c.UDPSize > 65535 {
+		errs = append(errs, fmt.Sprintf("UDP size %d is not between 512 and 65535", c.UDPSize))
+	}
+	if c.RequestTimeout.Duration <= 0 {
+		errs = append(errs, "request timeout has to be positive")
+	}
+	if len(c.Upstream.Servers) == 0 {
+		errs = append(errs, "no upstream server")
+	}
+	servers := len(errs)
+	for _, u := range c.Upstream.Servers {
+		if _, _, _, err := parseServer(u); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) == servers {
+		if _, err := c.Upstreams(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if _, err := passthrough.ParsePolicy(c.Upstream.Policy); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if c.Upstream.Timeout.Duration <= 0 {
+		errs = append(errs, "upstream timeout has to be positive")
+	}
+	if c.Upstream.TLS.IdleTimeout.Duration <= 0 {
+		errs = append(errs, "upstream TLS idle timeout has to be positive")
+	}
+	if c.Upstream.Attempts < 1 {
+		errs = append(errs, "upstream attempts have to be positive")
+	}
+	if c.Upstream.UDPSize < 512 || c.Upstream.UDPSize > 65535 {
+		errs = append(errs, fmt.Sprintf("upstream UDP size %d is not between 512 and 65535", c.Upstream.UDPSize))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, "TLS requires both a certificate and a key file")
+	}
+	if c.TLS.Enabled() {
+		for _, a := range c.TLS.Listen {
+			if _, _, err := net.SplitHostPort(a); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid TLS listen address %s", a))
+			}
+		}
+	}
+	if len(c.HTTPS.Listen) > 0 && !c.TLS.Enabled() {
+		errs = append(errs, "DNS over HTTPS requires a TLS certificate")
+	}
+	for _, a := range c.HTTPS.Listen {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid HTTPS listen address %s", a))
+		}
+	}
+	if !strings.HasPrefix(c.HTTPS.Path, "/") {
+		errs = append(errs, fmt.Sprintf("HTTPS path %s has to start with /", c.HTTPS.Path))
+	}
+	if c.Cache.MaxEntries < 0 {
+		errs = append(errs, "negative cache size")
+	}
+	for _, z := range c.Zones {
+		if z.Path == "" {
+			errs = append(errs, "zone source without path")
+		}
+		if z.Format != "" && z.Format != parser.FormatTOML && z.Format != parser.FormatMaster {
+			errs = append(errs, fmt.Sprintf("unknown format %s of zone source %s", z.Format, z.Path))
+		}
+	}
+	if c.Reload.Watch && c.Reload.Interval.Duration <= 0 {
+		errs = append(errs, "reload interval has to be positive")
+	}
+	if _, err := ParseNetworks(c.ACL.Allow); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := ParseNetworks(c.ACL.Deny); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Sources returns the zone sources in the format used by the record parser
+func (c *Config) Sources() []parser.Source {
+	if len(c.Zones) == 0 {
+		return []parser.Source{{Path: parser.ZonesFile, Optional: true}}
+	}
+	out := make([]parser.Source, len(c.Zones))
+	for i, z := range c.Zones {
+		out[i] = parser.Source{Path: z.Path, Format: z.Format, Origin: z.Origin}
+	}
+	return out
+}
+
+// Upstreams returns the upstream resolvers with their transports
+func (c *Config) Upstreams() ([]*passthrough.Upstream, error) {
+	out := make([]*passthrough.Upstream, 0, len(c.Upstream.Servers))
+	for _, s := range c.Upstream.Servers {
+		scheme, addr, name, err := parseServer(s)
+		if err != nil {
+			return nil, err
+		}
+		u := &passthrough.Upstream{Addr: s}
+		switch scheme {
+		case schemeTLS, schemeHTTPS:
+			config, err := passthrough.TLSConfig(name, c.Upstream.TLS.CAFile, c.Upstream.TLS.Pins)
+			if err != nil {
+				return nil, err
+			}
+			if scheme == schemeHTTPS {
+				t := passthrough.NewHTTPS(addr, config)
+				t.GET = c.Upstream.HTTPSGet
+				t.Timeout = c.Upstream.Timeout.Duration
+				u.Transport = t
+				break
+			}
+			t := passthrough.NewTLS(addr, config)
+			t.Timeout = c.Upstream.Timeout.Duration
+			t.IdleTimeout = c.Upstream.TLS.IdleTimeout.Duration
+			u.Transport = t
+		default:
+			t := passthrough.NewUDP(addr)
+			t.Size = c.Upstream.UDPSize
+			t.Timeout = c.Upstream.Timeout.Duration
+			t.Attempts = c.Upstream.Attempts
+			t.Randomize0x20 = c.Upstream.CaseRandomization
+			u.Transport = t
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// Schemes of upstream servers
+const (
+	schemeUDP   = "udp"
+	schemeTLS   = "tls"
+	schemeHTTPS = "https"
+)
+
+// parseServer splits an upstream server into its scheme, its address and the name its certificate is verified for.
+// The address of an HTTPS server is its URL.
+func parseServer(s string) (scheme, addr, name string, err error) {
+	invalid := fmt.Errorf("invalid upstream server %s", s)
+	switch {
+	case strings.HasPrefix(s, "https://"):
+		u, err := url.Parse(s)
+		if err != nil || u.Hostname() == "" {
+			return "", "", "", invalid
+		}
+		return schemeHTTPS, s, u.Hostname(), nil
+	case strings.HasPrefix(s, "tls://"):
+		scheme, addr = schemeTLS, strings.TrimPrefix(s, "tls://")
+		if i := strings.Index(addr, "#"); i >= 0 {
+			addr, name = addr[:i], addr[i+1:]
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(strings.Trim(addr, "[]"), "853")
+		}
+	default:
+		scheme, addr = schemeUDP, s
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return "", "", "", invalid
+	}
+	if scheme == schemeTLS && name == "" {
+		name = host
+	}
+	return scheme, addr, name, nil
+}
+
+// ParseNetworks parses networks in CIDR notation and single IP addresses
+func ParseNetworks(s []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(s))
+	for _, v := range s {
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %s", v)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+listen = [":5353", "127.0.0.1:53"]
+udp_size = 4096
+idle_timeout = "30s"
+minimal_responses = true
+
+[upstream]
+servers = ["9.9.9.9:53", "1.1.1.1:53"]
+policy = "round-robin"
+
+[cache]
+max_entries = 1000
+max_ttl = 86400
+
+[[zones]]
+path = "zones.toml"
+
+[[zones]]
+path = "example.com.zone"
+origin = "example.com"
+
+[reload]
+watch = true
+interval = "5s"
+
+[log]
+queries = true
+
+[acl]
+allow = ["10.0.0.0/8", "::1"]
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "dns.toml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func TestParse(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	cfg, check, err := Parse("dns", []string{"-config", path}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.Listen = []string{":5353", "127.0.0.1:53"}
+	want.UDPSize = 4096
+	want.IdleTimeout = Duration{30 * time.Second}
+	want.MinimalResponses = true
+	want.Upstream.Servers = []string{"9.9.9.9:53", "1.1.1.1:53"}
+	want.Upstream.Policy = "round-robin"
+	want.Cache = Cache{MaxEntries: 1000, MaxTTL: 86400}
+	want.Zones = []Zone{{Path: "zones.toml"}, {Path: "example.com.zone", Origin: "example.com"}}
+	want.Reload = Reload{Watch: true, Interval: Duration{5 * time.Second}}
+	want.Log.Queries = true
+	want.ACL.Allow = []string{"10.0.0.0/8", "::1"}
+	if check || !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Parse() = %+v, %v, want %+v", cfg, check, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Config.Validate() error = %v", err)
+	}
+}
+
+func TestParse_Overrides(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		wantCheck bool
+		check     func(c *Config) bool
+	}{
+		{"Config from environment", nil, map[string]string{"GODNS_CONFIG": path}, false, func(c *Config) bool { return c.UDPSize == 4096 }},
+		{"Environment overrides file", []string{"-config", path}, map[string]string{"GODNS_UDP_SIZE": "2048", "GODNS_UPSTREAM": "8.8.8.8:53, 8.8.4.4:53"}, false, func(c *Config) bool {
+			return c.UDPSize == 2048 && reflect.DeepEqual(c.Upstream.Servers, []string{"8.8.8.8:53", "8.8.4.4:53"})
+		}},
+		{"Flags override environment", []string{"-config", path, "-udp-size", "1024"}, map[string]string{"GODNS_UDP_SIZE": "2048"}, false, func(c *Config) bool { return c.UDPSize == 1024 }},
+		{"Repeated zones flag", []string{"-zones", "a.toml", "--zones", "example.org=b.zone"}, nil, false, func(c *Config) bool {
+			return reflect.DeepEqual(c.Zones, []Zone{{Path: "a.toml"}, {Path: "b.zone", Origin: "example.org"}})
+		}},
+		{"Zones flag with comma", []string{"-zones", "a,b.toml"}, map[string]string{"GODNS_ZONES": "c.toml"}, false, func(c *Config) bool {
+			return reflect.DeepEqual(c.Zones, []Zone{{Path: "a,b.toml"}})
+		}},
+		{"Zones from environment", nil, map[string]string{"GODNS_ZONES": "a.toml, example.org=b.zone"}, false, func(c *Config) bool {
+			return reflect.DeepEqual(c.Zones, []Zone{{Path: "a.toml"}, {Path: "b.zone", Origin: "example.org"}})
+		}},
+		{"Boolean flags", []string{"-log-queries=false", "-watch-zones", "-config", path}, nil, false, func(c *Config) bool { return !c.Log.Queries && c.Reload.Watch }},
+		{"Check config", []string{"--check-config"}, nil, true, func(c *Config) bool { return reflect.DeepEqual(c, Default()) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, check, err := Parse("dns", tt.args, env(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if check != tt.wantCheck || !tt.check(cfg) {
+				t.Errorf("Parse() = %+v, %v", cfg, check)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"Missing explicit file", []string{"-config", "/nonexistent/dns.toml"}, nil, "no such file"},
+		{"Invalid file", []string{"-config", writeConfig(t, "listen = 53\n")}, nil, "dns.toml"},
+		{"Invalid duration", []string{"-config", writeConfig(t, "idle_timeout = \"soon\"\n")}, nil, "dns.toml"},
+		{"Unknown flag", []string{"-unknown"}, nil, "not defined"},
+		{"Invalid environment variable", nil, map[string]string{"GODNS_CACHE_SIZE": "many"}, "cache-size"},
+		{"Help", []string{"-h"}, nil, "-check-config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Parse("dns", tt.args, env(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{"Default", func(c *Config) {}, ""},
+		{"Listen address without port", func(c *Config) { c.Listen = []string{"127.0.0.1"} }, "invalid listen address"},
+		{"No listen address", func(c *Config) { c.Listen = nil }, "no listen address"},
+		{"UDP size", func(c *Config) { c.UDPSize = 100 }, "UDP size 100"},
+		{"Request timeout", func(c *Config) { c.RequestTimeout.Duration = 0 }, "request timeout has to be positive"},
+		{"Upstream without host", func(c *Config) { c.Upstream.Servers = []string{":53"} }, "invalid upstream server"},
+		{"No upstream", func(c *Config) { c.Upstream.Servers = nil }, "no upstream server"},
+		{"Upstream policy", func(c *Config) { c.Upstream.Policy = "fastest" }, "unknown upstream policy fastest"},
+		{"Upstream TLS pin", func(c *Config) { c.Upstream.Servers = []string{"tls://1.1.1.1"}; c.Upstream.TLS.Pins = []string{"abc"} }, "invalid SPKI pin abc"},
+		{"Upstream TLS idle timeout", func(c *Config) { c.Upstream.TLS.IdleTimeout.Duration = 0 }, "upstream TLS idle timeout has to be positive"},
+		{"TLS key", func(c *Config) { c.TLS.CertFile = "cert.pem" }, "TLS requires both a certificate and a key file"},
+		{"HTTPS certificate", func(c *Config) { c.HTTPS.Listen = []string{":443"} }, "DNS over HTTPS requires a TLS certificate"},
+		{"Zone format", func(c *Config) { c.Zones = []Zone{{Path: "a", Format: "xml"}} }, "unknown format xml"},
+		{"ACL", func(c *Config) { c.ACL.Deny = []string{"10.0.0.0/33"} }, "invalid network"},
+		{"Multiple errors", func(c *Config) { c.Listen = nil; c.Cache.MaxEntries = -1 }, "no listen address; negative cache size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(c)
+			err := c.Validate()
+			if (err != nil) != (tt.wantErr != "") || err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Config.Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	got, err := ParseNetworks([]string{"10.0.0.0/8", "192.0.2.1", "fd00::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "fd00::1/128"}
+	for i, n := range got {
+		if n.String() != want[i] {
+			t.Errorf("ParseNetworks()[%d] = %s, want %s", i, n.String(), want[i])
+		}
+	}
+	if _, err := ParseNetworks([]string{"example.com"}); err == nil {
+		t.Error("ParseNetworks() accepted a host name")
+	}
+}
+
+func Test_parseServer(t *testing.T) {
+	tests := []struct {
+		s          string
+		wantScheme string
+		wantAddr   string
+		wantName   string
+		wantErr    bool
+	}{
+		{"1.1.1.1:53", schemeUDP, "1.1.1.1:53", "", false},
+		{"tls://1.1.1.1", schemeTLS, "1.1.1.1:853", "1.1.1.1", false},
+		{"tls://1.1.1.1:8853#cloudflare-dns.com", schemeTLS, "1.1.1.1:8853", "cloudflare-dns.com", false},
+		{"tls://[2606:4700:4700::1111]#cloudflare-dns.com", schemeTLS, "[2606:4700:4700::1111]:853", "cloudflare-dns.com", false},
+		{"https://cloudflare-dns.com/dns-query", schemeHTTPS, "https://cloudflare-dns.com/dns-query", "cloudflare-dns.com", false},
+		{"1.1.1.1", "", "", "", true},
+		{"tls://#name", "", "", "", true},
+		{"https:///dns-query", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			scheme, addr, name, err := parseServer(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseServer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if scheme != tt.wantScheme || addr != tt.wantAddr || name != tt.wantName {
+				t.Errorf("parseServer() = %s, %s, %s, want %s, %s, %s", scheme, addr, name, tt.wantScheme, tt.wantAddr, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding the configuration file
+const EnvPrefix = "GODNS_"
+
+// Parse builds the configuration from the defaults, the configuration file, environment variables and the command-line arguments args.
+// Each of them overrides the settings of the former. check reports whether --check-config was passed.
+func Parse(name string, args []string, getenv func(string) string) (cfg *Config, check bool, err error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	file := fs.String("config", "", "configuration `file` (default "+DefaultFile+" if it exists)")
+	fs.BoolVar(&check, "check-config", false, "validate the configuration and the zones and exit")
+	var o overrides
+	fs.Var(&o.listen, "listen", "comma separated `addresses` to listen on")
+	fs.Var(&o.upstream, "upstream", "comma separated upstream `servers` as host:port")
+	fs.Var(&o.zones, "zones", "zone `source` as path or origin=path (may be repeated)")
+	fs.Var(&o.allow, "allow", "comma separated `networks` allowed to send requests")
+	fs.Var(&o.deny, "deny", "comma separated `networks` whose requests are refused")
+	fs.Int("udp-size", 0, "UDP buffer size and advertised EDNS payload `size`")
+	fs.Int("cache-size", 0, "maximum number of cached record `sets`")
+	fs.String("log-file", "", "log `file`")
+	fs.Bool("log-queries", false, "log every request")
+	fs.Bool("minimal-responses", false, "omit authority and additional records from local answers")
+	fs.Bool("watch-zones", false, "reload zones when their files change")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, false, fmt.Errorf("usage of %s:\n%s", name, usage(fs))
+		}
+		return nil, false, err
+	}
+
+	cfg = Default()
+	path, explicit := *file, *file != ""
+	if !explicit {
+		path = getenv(EnvPrefix + "CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = DefaultFile
+	}
+	if err := cfg.ReadFile(path); err != nil && (explicit || !os.IsNotExist(err)) {
+		return nil, false, err
+	}
+
+	values := make(map[string]string)
+	for _, f := range []string{"listen", "upstream", "zones", "allow", "deny", "udp-size", "cache-size", "log-file", "log-queries", "minimal-responses", "watch-zones"} {
+		if v := getenv(EnvPrefix + strings.ToUpper(strings.Replace(f, "-", "_", -1))); v != "" {
+			values[f] = v
+		}
+	}
+	zones := false
+	fs.Visit(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+		zones = zones || f.Name == "zones"
+	})
+	// Zone sources given as flags are used as parsed since their paths may contain commas
+	if zones {
+		delete(values, "zones")
+	}
+	if err := cfg.apply(values); err != nil {
+		return nil, false, err
+	}
+	if zones {
+		cfg.Zones = append([]Zone(nil), o.zones...)
+	}
+	return cfg, check, nil
+}
+
+// apply sets the options given by flag name in values
+func (c *Config) apply(values map[string]string) error {
+	for name, v := range values {
+		var err error
+		switch name {
+		case "listen":
+			c.Listen = split(v)
+		case "upstream":
+			c.Upstream.Servers = split(v)
+		case "zones":
+			var z zoneList
+			for _, s := range split(v) {
+				if err = z.Set(s); err != nil {
+					break
+				}
+			}
+			c.Zones = z
+		case "allow":
+			c.ACL.Allow = split(v)
+		case "deny":
+			c.ACL.Deny = split(v)
+		case "udp-size":
+			c.UDPSize, err = strconv.Atoi(v)
+		case "cache-size":
+			c.Cache.MaxEntries, err = strconv.Atoi(v)
+		case "log-file":
+			c.Log.File = v
+		case "log-queries":
+			c.Log.Queries, err = strconv.ParseBool(v)
+		case "minimal-responses":
+			c.MinimalResponses, err = strconv.ParseBool(v)
+		case "watch-zones":
+			c.Reload.Watch, err = strconv.ParseBool(v)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s: %s", v, name, err.Error())
+		}
+	}
+	return nil
+}
+
+// overrides holds the values of list flags
+type overrides struct {
+	listen, upstream, allow, deny list
+	zones                         zoneList
+}
+
+// list is a flag holding a comma separated list
+type list []string
+
+func (l *list) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(v string) error {
+	*l = split(v)
+	return nil
+}
+
+// zoneList is a flag holding zone sources given as path or origin=path. Every use of the flag adds a source.
+type zoneList []Zone
+
+func (z *zoneList) String() string {
+	s := make([]string, len(*z))
+	for i, src := range *z {
+		s[i] = src.Path
+		if src.Origin != "" {
+			s[i] = src.Origin + "=" + src.Path
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func (z *zoneList) Set(v string) error {
+	src := Zone{Path: v}
+	if i := strings.Index(v, "="); i >= 0 {
+		src.Origin, src.Path = v[:i], v[i+1:]
+	}
+	if src.Path == "" {
+		return fmt.Errorf("zone source %q has no path", v)
+	}
+	*z = append(*z, src)
+	return nil
+}
+
+func split(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func usage(fs *flag.FlagSet) string {
+	var b strings.Builder
+	fs.SetOutput(&b)
+	fs.PrintDefaults()
+	return b.String()
+}
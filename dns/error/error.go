@@ -18,9 +18,13 @@ func (e Error) Error() string {
 	return fmt.Sprintf("DNS error with code %d. Authoritative response: %t", e.RCode, e.AA)
 }
 
-// Message returns the error as a DNS message
+// Message returns the error as a DNS message. Extended response codes are stored in an OPT record.
 func (e Error) Message(id [2]byte, q ...query.Query) *message.Message {
-	return message.New(header.NewErrorHeader(id, e.AA, e.RCode), q, nil, nil, nil)
+	msg := message.New(header.NewErrorHeader(id, e.AA, e.RCode&0xF), q, nil, nil, nil)
+	if e.RCode > 0xF {
+		msg.SetResponseCode(uint16(e.RCode))
+	}
+	return msg
 }
 
 // IsError returns true if the RCode is not 0 (NoError)
@@ -62,3 +66,6 @@ const (
 	// Not Zone = 10
 	NotZone
 )
+
+// Bad OPT Version = 16 (requires EDNS)
+const BadVersion uint8 = 16
@@ -57,6 +57,15 @@ func (h Header) Truncated() bool {
 	return ((h.Flags[0] & 0x3) >> 1) == 1
 }
 
+// SetTruncated sets or clears the TC bit
+func (h *Header) SetTruncated(tc bool) {
+	if tc {
+		h.Flags[0] |= 0x2
+	} else {
+		h.Flags[0] &^= 0x2
+	}
+}
+
 // RecursionDesired returns true if the RD bit is set
 func (h Header) RecursionDesired() bool {
 	return (h.Flags[0] & 0x1) == 1
@@ -77,6 +86,11 @@ func (h Header) ResponseCode() uint8 {
 	return h.Flags[1] & 0xF
 }
 
+// SetResponseCode stores the lower four bits of rcode in the flags. Larger codes require an OPT record carrying the upper bits.
+func (h *Header) SetResponseCode(rcode uint8) {
+	h.Flags[1] = h.Flags[1]&0xF0 | rcode&0xF
+}
+
 // NewQueryHeader returns a new Header with the settings for a standard request
 func NewQueryHeader(rd bool) *Header {
 	h := new(Header)
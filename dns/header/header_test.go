@@ -102,6 +102,28 @@ func TestHeader_Truncated(t *testing.T) {
 	}
 }
 
+func TestHeader_SetTruncated(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Header
+		tc   bool
+		want [2]byte
+	}{
+		{"Set", Header{[2]byte{0x0, 0x0}, [2]byte{0x0, 0x0}, 0, 0, 0, 0}, true, [2]byte{0x2, 0x0}},
+		{"Set keeps flags", Header{[2]byte{0x0, 0x0}, [2]byte{0x85, 0x80}, 0, 0, 0, 0}, true, [2]byte{0x87, 0x80}},
+		{"Clear", Header{[2]byte{0x0, 0x0}, [2]byte{0x87, 0x80}, 0, 0, 0, 0}, false, [2]byte{0x85, 0x80}},
+		{"Clear unset", Header{[2]byte{0x0, 0x0}, [2]byte{0x0, 0x0}, 0, 0, 0, 0}, false, [2]byte{0x0, 0x0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.h.SetTruncated(tt.tc)
+			if tt.h.Flags != tt.want {
+				t.Errorf("Header.SetTruncated() flags = %X, want %X", tt.h.Flags, tt.want)
+			}
+		})
+	}
+}
+
 func TestHeader_RecursionDesired(t *testing.T) {
 	tests := []struct {
 		name string
@@ -174,6 +196,27 @@ func TestHeader_ResponseCode(t *testing.T) {
 	}
 }
 
+func TestHeader_SetResponseCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		h     Header
+		rcode uint8
+		want  [2]byte
+	}{
+		{"Set", Header{[2]byte{0x0, 0x0}, [2]byte{0x80, 0x80}, 0, 0, 0, 0}, 3, [2]byte{0x80, 0x83}},
+		{"Replace", Header{[2]byte{0x0, 0x0}, [2]byte{0x80, 0x85}, 0, 0, 0, 0}, 2, [2]byte{0x80, 0x82}},
+		{"Extended", Header{[2]byte{0x0, 0x0}, [2]byte{0x80, 0x80}, 0, 0, 0, 0}, 16, [2]byte{0x80, 0x80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.h.SetResponseCode(tt.rcode)
+			if tt.h.Flags != tt.want {
+				t.Errorf("Header.SetResponseCode() flags = %X, want %X", tt.h.Flags, tt.want)
+			}
+		})
+	}
+}
+
 func TestHeader_String(t *testing.T) {
 	tests := []struct {
 		name string
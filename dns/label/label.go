@@ -2,17 +2,19 @@ package label
 
 import (
 	"errors"
-	"fmt"
 	"regexp"
 	"strings"
 )
 
-var re *regexp.Regexp = regexp.MustCompile(`^[a-z][a-z0-9]+$`)
+var re *regexp.Regexp = regexp.MustCompile(`^(\*|[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?)$`)
 
 // Label is a type used to store a DNS label
 type Label []string
 
 func (l Label) String() string {
+	if len(l) == 0 {
+		return "."
+	}
 	s := ""
 	for _, v := range l {
 		s += v + "."
@@ -20,41 +22,59 @@ func (l Label) String() string {
 	return s
 }
 
+// maxHops limits the number of compression pointers followed while reading a single name
+const maxHops = 32
+
+// maxNameLength is the maximum length of an encoded name (RFC 1035 section 3.1)
+const maxNameLength = 255
+
 // GetLabelsFromMessage extracts a label from a message starting at start and returns that label as well as it's end position.
+// Compression pointers have to point to an earlier position than the name they are part of so that loops are impossible.
 func GetLabelsFromMessage(data []byte, start int) (Label, int, error) {
-	if start >= len(data) {
+	if start < 0 || start >= len(data) {
 		return nil, 0, errors.New("label outside data range")
 	}
-	w := data[start:]
 	labels := make([]string, 0)
-	m := 0
+	pos, segment, end := start, start, -1
+	length, hops := 1, 0
 	for {
-		if len(w) < 1 {
+		if pos >= len(data) {
 			return nil, 0, errors.New("label section too short")
 		}
-		l := w[0]
-		if l == 0 {
-			m++
-			break
-		} else if l == 192 {
-			if len(w) < 2 {
+		l := data[pos]
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = pos + 1
+			}
+			return labels, end, nil
+		case l&0xC0 == 0xC0:
+			if pos+1 >= len(data) {
 				return nil, 0, errors.New("label section to short for redirect")
 			}
-			s, _, err := GetLabelsFromMessage(data, int(w[1]))
-			if err != nil {
-				return nil, 0, err
+			ptr := int(l&0x3F)<<8 | int(data[pos+1])
+			if ptr >= segment {
+				return nil, 0, errors.New("compression pointer has to point to an earlier name")
+			}
+			if hops++; hops > maxHops {
+				return nil, 0, errors.New("too many compression pointers")
 			}
-			return s, start + 2, nil
-		} else if l >= 64 {
+			if end < 0 {
+				end = pos + 2
+			}
+			pos, segment = ptr, ptr
+			continue
+		case l >= 64:
 			return nil, 0, errors.New("labels cannot be larger than 63 bytes")
-		} else if int(l) >= len(w) {
+		case pos+int(l) >= len(data):
 			return nil, 0, errors.New("label section lenght exceeds data lenght")
 		}
-		labels = append(labels, string(w[1:1+l]))
-		w = w[1+l:]
-		m += int(1 + l)
+		if length += 1 + int(l); length > maxNameLength {
+			return nil, 0, errors.New("name exceeds 255 bytes")
+		}
+		labels = append(labels, string(data[pos+1:pos+1+int(l)]))
+		pos += 1 + int(l)
 	}
-	return labels, m, nil
 }
 
 // Encode encodes a label to the DNS message format
@@ -68,6 +88,34 @@ func (l Label) Encode() []byte {
 	return out
 }
 
+// Compression maps the names already written to a DNS message to their offsets so that they can be referenced by compression pointers (RFC 1035 section 4.1.4)
+type Compression map[string]int
+
+// maxPointer is the largest offset that can be stored in a compression pointer
+const maxPointer = 0x3FFF
+
+// EncodeCompressed encodes a label that will be written to a message at offset off.
+// The longest suffix already contained in c is replaced by a pointer and all new suffixes are added to c.
+// If c is nil, the label is encoded without compression.
+func (l Label) EncodeCompressed(c Compression, off int) []byte {
+	if c == nil {
+		return l.Encode()
+	}
+	var out []byte
+	for i := range l {
+		key := strings.ToLower(strings.Join(l[i:], "."))
+		if p, ok := c[key]; ok {
+			return append(out, 0xC0|byte(p>>8), byte(p))
+		}
+		if pos := off + len(out); pos <= maxPointer {
+			c[key] = pos
+		}
+		out = append(out, uint8(len(l[i])))
+		out = append(out, l[i]...)
+	}
+	return append(out, 0)
+}
+
 // Parse turns a string into a label or returns an error if that's not possible
 func Parse(i string) (Label, error) {
 	s := strings.Split(i, ".")
@@ -77,13 +125,16 @@ func Parse(i string) (Label, error) {
 	if len(s) < 1 {
 		return nil, errors.New("label cannot be empty")
 	}
+	length := 1
 	for n := range s {
 		s[n] = strings.ToLower(s[n])
-		fmt.Println(s[n])
-		fmt.Println(re.FindString(s[n]))
 		if !re.MatchString(s[n]) {
-			return nil, errors.New("label has to start with a letter and may only contain letters and numbers")
+			return nil, errors.New("label sections may only contain letters, numbers, hyphens and underscores or consist of a single asterisk")
 		}
+		length += 1 + len(s[n])
+	}
+	if length > maxNameLength {
+		return nil, errors.New("name exceeds 255 bytes")
 	}
 	return s, nil
 }
@@ -1,7 +1,9 @@
 package label
 
 import (
+	"bytes"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -13,6 +15,7 @@ func TestLabel_String(t *testing.T) {
 	}{
 		{"Normal", Label{"test", "example", "com"}, "test.example.com."},
 		{"Root", Label{""}, "."},
+		{"Empty", Label{}, "."},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -24,6 +27,7 @@ func TestLabel_String(t *testing.T) {
 }
 
 func TestGetLabelsFromMessage(t *testing.T) {
+	long := append([]byte{0x3F}, make([]byte, 63)...)
 	type args struct {
 		data  []byte
 		start int
@@ -37,6 +41,7 @@ func TestGetLabelsFromMessage(t *testing.T) {
 	}{
 		{"Normal", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00}, 0}, Label{"example", "com"}, 13, false},
 		{"Redirect", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00, 0xC0, 0x00}, 13}, Label{"example", "com"}, 15, false},
+		{"Redirect after label", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00, 0x03, 0x77, 0x77, 0x77, 0xC0, 0x00}, 13}, Label{"www", "example", "com"}, 19, false},
 		{"Redirect outside message bounds", args{[]byte{0xC0, 0xFF}, 0}, nil, 0, true},
 		{"Start Outside Message Range", args{[]byte{0x0, 0x0}, 12}, nil, 0, true},
 		{"Label length too short for redirect", args{[]byte{0xC0}, 0}, nil, 0, true},
@@ -44,6 +49,18 @@ func TestGetLabelsFromMessage(t *testing.T) {
 		{"Label length exceeds message length", args{[]byte{0x20}, 0}, nil, 0, true},
 		{"Label not properly terminated", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D}, 0}, nil, 0, true},
 		{"Section lenght too long", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F}, 0}, nil, 0, true},
+		{"Redirect beyond byte 255", args{append(append(make([]byte, 300), 0x03, 0x63, 0x6F, 0x6D, 0x00), 0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0xC1, 0x2C), 305}, Label{"example", "com"}, 315, false},
+		{"Redirect chain", args{[]byte{0x03, 0x63, 0x6F, 0x6D, 0x00, 0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0xC0, 0x00, 0x03, 0x77, 0x77, 0x77, 0xC0, 0x05}, 15}, Label{"www", "example", "com"}, 21, false},
+		{"Self reference", args{[]byte{0x00, 0x00, 0xC0, 0x02}, 2}, nil, 0, true},
+		{"Forward reference", args{[]byte{0xC0, 0x02, 0x03, 0x63, 0x6F, 0x6D, 0x00}, 0}, nil, 0, true},
+		{"Loop through earlier label", args{[]byte{0x01, 0x78, 0xC0, 0x00}, 2}, nil, 0, true},
+		{"Loop between pointers", args{[]byte{0x01, 0x78, 0xC0, 0x04, 0xC0, 0x00}, 4}, nil, 0, true},
+		{"Reserved label type", args{[]byte{0x80, 0x00}, 0}, nil, 0, true},
+		{"Extended label type", args{[]byte{0x41, 0x00}, 0}, nil, 0, true},
+		{"Negative start", args{[]byte{0x00}, -1}, nil, 0, true},
+		{"Name too long", args{append(bytes.Repeat(long, 4), 0x00), 0}, nil, 0, true},
+		{"Longest name", args{append(append(bytes.Repeat(long, 3), 0x3D), make([]byte, 62)...), 0}, Label{string(make([]byte, 63)), string(make([]byte, 63)), string(make([]byte, 63)), string(make([]byte, 61))}, 255, false},
+		{"Name too long through redirects", args{append(append(bytes.Repeat(long, 3), 0x00), append(long, 0xC0, 0x00)...), 193}, nil, 0, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -62,6 +79,24 @@ func TestGetLabelsFromMessage(t *testing.T) {
 	}
 }
 
+func FuzzGetLabelsFromMessage(f *testing.F) {
+	f.Add([]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00, 0x03, 0x77, 0x77, 0x77, 0xC0, 0x00}, 13)
+	f.Add([]byte{0x01, 0x78, 0xC0, 0x04, 0xC0, 0x00}, 4)
+	f.Add([]byte{0xC0, 0x00}, 0)
+	f.Fuzz(func(t *testing.T, data []byte, start int) {
+		l, end, err := GetLabelsFromMessage(data, start)
+		if err != nil {
+			return
+		}
+		if end <= start || end > len(data) {
+			t.Errorf("GetLabelsFromMessage() end = %d outside of (%d, %d]", end, start, len(data))
+		}
+		if n := len(l.Encode()); n > maxNameLength {
+			t.Errorf("GetLabelsFromMessage() returned name of %d bytes", n)
+		}
+	})
+}
+
 func TestLabel_Encode(t *testing.T) {
 	tests := []struct {
 		name string
@@ -80,6 +115,40 @@ func TestLabel_Encode(t *testing.T) {
 	}
 }
 
+func TestLabel_EncodeCompressed(t *testing.T) {
+	c := Compression{"example.com": 12}
+	tests := []struct {
+		name     string
+		l        Label
+		c        Compression
+		off      int
+		want     []byte
+		wantKeys []string
+	}{
+		{"Without table", Label{"example", "com"}, nil, 40, []byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00}, nil},
+		{"Full match", Label{"example", "com"}, c, 40, []byte{0xC0, 0x0C}, []string{"example.com"}},
+		{"Case insensitive match", Label{"EXAMPLE", "Com"}, c, 40, []byte{0xC0, 0x0C}, []string{"example.com"}},
+		{"Suffix match", Label{"www", "example", "com"}, c, 40, []byte{0x03, 0x77, 0x77, 0x77, 0xC0, 0x0C}, []string{"www.example.com"}},
+		{"No match", Label{"example", "org"}, c, 300, []byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x6F, 0x72, 0x67, 0x00}, []string{"example.org", "org"}},
+		{"Offset beyond pointer range", Label{"mail", "example", "net"}, c, 0x4000, []byte{0x04, 0x6D, 0x61, 0x69, 0x6C, 0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x6E, 0x65, 0x74, 0x00}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.l.EncodeCompressed(tt.c, tt.off); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Label.EncodeCompressed() = %v, want %v", got, tt.want)
+			}
+			for _, k := range tt.wantKeys {
+				if _, ok := tt.c[k]; !ok {
+					t.Errorf("Label.EncodeCompressed() did not add %q to the table", k)
+				}
+			}
+		})
+	}
+	if _, ok := c["mail.example.net"]; ok {
+		t.Error("Label.EncodeCompressed() added offset that cannot be used by a pointer")
+	}
+}
+
 func TestParse(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -89,10 +158,20 @@ func TestParse(t *testing.T) {
 	}{
 		{"Normal", "example.com", Label{"example", "com"}, false},
 		{"With Root", "example.com.", Label{"example", "com"}, false},
-		{"Null", string(0x0), nil, true},
+		{"Null", "\x00", nil, true},
 		{"Empty", "", nil, true},
 		{"Invalid characters", "²³.test.example.com", nil, true},
 		{"Empty segment", "test..example.com", nil, true},
+		{"Mixed case", "WWW.Example.COM", Label{"www", "example", "com"}, false},
+		{"Digits and hyphens", "10ca-1.in-addr.arpa", Label{"10ca-1", "in-addr", "arpa"}, false},
+		{"Single character", "a.b", Label{"a", "b"}, false},
+		{"Service", "_sip._tcp.example.com", Label{"_sip", "_tcp", "example", "com"}, false},
+		{"Wildcard", "*.example.com", Label{"*", "example", "com"}, false},
+		{"Leading hyphen", "-test.example.com", nil, true},
+		{"Trailing hyphen", "test-.example.com", nil, true},
+		{"Partial wildcard", "a*.example.com", nil, true},
+		{"Section too long", strings.Repeat("a", 64) + ".com", nil, true},
+		{"Name too long", strings.Repeat(strings.Repeat("a", 63)+".", 4) + "com", nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
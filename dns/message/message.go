@@ -1,10 +1,11 @@
 package message
 
 import (
-	"errors"
-
 	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
 	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
 	"github.com/fossoreslp/go-dns/dns/response"
 )
 
@@ -15,6 +16,8 @@ type Message struct {
 	Answers     []response.Response
 	Authorities []response.Response
 	Additional  []response.Response
+	// Compress enables name compression when encoding the message
+	Compress bool
 }
 
 // Parse parses a DNS message
@@ -23,9 +26,6 @@ func Parse(msg []byte) (*Message, error) {
 	if err != nil {
 		return nil, err
 	}
-	if h.Truncated() {
-		return nil, errors.New("truncated messages cannot be handled, yet")
-	}
 	q, qend, err := query.Parse(msg, h.QuestionCount)
 	if err != nil {
 		return nil, err
@@ -34,7 +34,7 @@ func Parse(msg []byte) (*Message, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Message{h, q, ans, auth, add}, nil
+	return &Message{h, q, ans, auth, add, true}, nil
 }
 
 func (msg Message) String() string {
@@ -63,7 +63,7 @@ func rArrToString(rs []response.Response) string {
 	return out
 }
 
-// New creates a new message with the supplied contents
+// New creates a new message with the supplied contents. Name compression is enabled.
 func New(h *header.Header, qs []query.Query, as []response.Response, ns []response.Response, add []response.Response) *Message {
 	if h == nil {
 		return nil
@@ -72,14 +72,14 @@ func New(h *header.Header, qs []query.Query, as []response.Response, ns []respon
 	h.AnswerCount = uint16(len(as))
 	h.NSCount = uint16(len(ns))
 	h.AdditionalCount = uint16(len(add))
-	return &Message{h, qs, as, ns, add}
+	return &Message{h, qs, as, ns, add, true}
 }
 
 // Encode returns the message in encoded DNS message format
 func (msg Message) Encode() []byte {
 	msg.Header.QuestionCount = uint16(len(msg.Questions))
 
-	msg.Header.AdditionalCount = uint16(len(msg.Answers))
+	msg.Header.AnswerCount = uint16(len(msg.Answers))
 
 	msg.Header.NSCount = uint16(len(msg.Authorities))
 
@@ -87,21 +87,106 @@ func (msg Message) Encode() []byte {
 
 	b := msg.Header.Encode()
 
+	var c label.Compression
+	if msg.Compress {
+		c = make(label.Compression)
+	}
+
 	for _, v := range msg.Questions {
-		b = append(b, v.Encode()...)
+		b = append(b, v.EncodeCompressed(c, len(b))...)
 	}
 
 	for _, v := range msg.Answers {
-		b = append(b, v.Encode()...)
+		b = append(b, v.EncodeCompressed(c, len(b))...)
 	}
 
 	for _, v := range msg.Authorities {
-		b = append(b, v.Encode()...)
+		b = append(b, v.EncodeCompressed(c, len(b))...)
 	}
 
 	for _, v := range msg.Additional {
-		b = append(b, v.Encode()...)
+		b = append(b, v.EncodeCompressed(c, len(b))...)
 	}
 
 	return b
 }
+
+// removeAdditional removes the last record from the additional section that is not an OPT record. It returns false if there is none.
+func (msg *Message) removeAdditional() bool {
+	for i := len(msg.Additional) - 1; i >= 0; i-- {
+		if msg.Additional[i].Type != names.OPT {
+			msg.Additional = append(msg.Additional[:i], msg.Additional[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Truncate removes whole records from the end of the message until its encoded form fits into size bytes.
+// Records are removed from the additional section first (keeping the OPT record), then from the authority and answer sections.
+// The TC bit is set if answers had to be removed or if authority records of a message without answers, which are required for referrals and negative answers, had to be removed.
+func (msg *Message) Truncate(size int) {
+	for len(msg.Encode()) > size {
+		switch {
+		case msg.removeAdditional():
+		case len(msg.Authorities) > 0:
+			msg.Authorities = msg.Authorities[:len(msg.Authorities)-1]
+			if len(msg.Answers) == 0 {
+				msg.Header.SetTruncated(true)
+			}
+		case len(msg.Answers) > 0:
+			msg.Answers = msg.Answers[:len(msg.Answers)-1]
+			msg.Header.SetTruncated(true)
+		default:
+			return
+		}
+	}
+}
+
+// OPT returns the EDNS information of the message or nil if it does not contain an OPT record
+func (msg *Message) OPT() *record.OPT {
+	for _, r := range msg.Additional {
+		if o, ok := r.Record.(*record.OPT); ok && r.Type == names.OPT {
+			return o
+		}
+	}
+	return nil
+}
+
+// SetOPT replaces the OPT record of the message with o. Passing nil removes it.
+func (msg *Message) SetOPT(o *record.OPT) {
+	add := make([]response.Response, 0, len(msg.Additional)+1)
+	for _, r := range msg.Additional {
+		if r.Type != names.OPT {
+			add = append(add, r)
+		}
+	}
+	if o != nil {
+		add = append(add, response.NewOPT(o))
+	}
+	msg.Additional = add
+	msg.Header.AdditionalCount = uint16(len(add))
+}
+
+// ResponseCode returns the full response code including the upper bits stored in the OPT record
+func (msg *Message) ResponseCode() uint16 {
+	rcode := uint16(msg.Header.ResponseCode())
+	if o := msg.OPT(); o != nil {
+		rcode |= uint16(o.ExtendedRCode) << 4
+	}
+	return rcode
+}
+
+// SetResponseCode sets the response code of the message. Codes larger than 15 require EDNS and add an OPT record if necessary.
+func (msg *Message) SetResponseCode(rcode uint16) {
+	msg.Header.SetResponseCode(uint8(rcode & 0xF))
+	o := msg.OPT()
+	if o == nil && rcode <= 0xF {
+		return
+	}
+	if o == nil {
+		o = &record.OPT{UDPSize: 512}
+	}
+	o.ExtendedRCode = uint8(rcode >> 4)
+	msg.SetOPT(o)
+}
@@ -0,0 +1,179 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+func records(n int) []response.Response {
+	out := make([]response.Response, n)
+	for i := range out {
+		out[i] = response.New(label.Label{"example", "com"}, names.A, 3600, []byte{10, 0, 0, byte(i)})
+	}
+	return out
+}
+
+func TestMessage_Truncate(t *testing.T) {
+	q := []query.Query{query.New(label.Label{"example", "com"}, names.QTYPE(names.A))}
+	// Header: 12 bytes, question: 17 bytes, each record: 27 bytes without compression
+	tests := []struct {
+		name           string
+		msg            *Message
+		size           int
+		wantAnswers    int
+		wantAuthority  int
+		wantAdditional int
+		wantTC         bool
+	}{
+		{"Fits", New(header.NewAnswerHeader([2]byte{}, false, true), q, records(2), records(1), records(1)), 512, 2, 1, 1, false},
+		{"Drop additional", New(header.NewAnswerHeader([2]byte{}, false, true), q, records(2), records(1), records(2)), 29 + 4*27, 2, 1, 1, false},
+		{"Drop authority", New(header.NewAnswerHeader([2]byte{}, false, true), q, records(2), records(2), records(2)), 29 + 3*27, 2, 1, 0, false},
+		{"Drop authority without answers", New(header.NewAnswerHeader([2]byte{}, false, true), q, nil, records(3), nil), 29 + 2*27, 0, 2, 0, true},
+		{"Drop answers", New(header.NewAnswerHeader([2]byte{}, false, true), q, records(20), nil, nil), 512, 17, 0, 0, true},
+		{"Nothing fits", New(header.NewAnswerHeader([2]byte{}, false, true), q, records(2), nil, nil), 20, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.Compress = false
+			tt.msg.Truncate(tt.size)
+			if len(tt.msg.Answers) != tt.wantAnswers || len(tt.msg.Authorities) != tt.wantAuthority || len(tt.msg.Additional) != tt.wantAdditional {
+				t.Errorf("Message.Truncate() left %d/%d/%d records, want %d/%d/%d", len(tt.msg.Answers), len(tt.msg.Authorities), len(tt.msg.Additional), tt.wantAnswers, tt.wantAuthority, tt.wantAdditional)
+			}
+			if got := tt.msg.Header.Truncated(); got != tt.wantTC {
+				t.Errorf("Message.Truncate() TC = %v, want %v", got, tt.wantTC)
+			}
+			out := tt.msg.Encode()
+			if len(out) > tt.size && tt.wantAnswers > 0 {
+				t.Errorf("Message.Truncate() encoded size = %d, want <= %d", len(out), tt.size)
+			}
+			parsed, err := Parse(out)
+			if err != nil {
+				t.Fatalf("Parse() of truncated message failed: %v", err)
+			}
+			if int(parsed.Header.AnswerCount) != tt.wantAnswers {
+				t.Errorf("Message.Truncate() ANCOUNT = %d, want %d", parsed.Header.AnswerCount, tt.wantAnswers)
+			}
+		})
+	}
+}
+
+func TestMessage_SetOPT(t *testing.T) {
+	q := []query.Query{query.New(label.Label{"example", "com"}, names.QTYPE(names.A))}
+	msg := New(header.NewQueryHeader(true), q, nil, nil, records(1))
+	if msg.OPT() != nil {
+		t.Fatal("Message.OPT() returned record for message without EDNS")
+	}
+	msg.SetOPT(&record.OPT{UDPSize: 1232, DO: true})
+	msg.SetOPT(&record.OPT{UDPSize: 4096})
+	parsed, err := Parse(msg.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := parsed.OPT()
+	if o == nil || o.UDPSize != 4096 || o.DO {
+		t.Errorf("Message.OPT() = %v, want UDP size 4096 without DO", o)
+	}
+	if len(parsed.Additional) != 2 {
+		t.Errorf("Message.SetOPT() left %d additional records, want 2", len(parsed.Additional))
+	}
+	parsed.SetOPT(nil)
+	if parsed.OPT() != nil || len(parsed.Additional) != 1 {
+		t.Error("Message.SetOPT(nil) did not remove the OPT record")
+	}
+}
+
+func TestMessage_SetResponseCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		rcode   uint16
+		edns    bool
+		wantOPT bool
+	}{
+		{"Plain", 3, false, false},
+		{"Plain with EDNS", 3, true, true},
+		{"Extended", 16, false, true},
+		{"Extended with EDNS", 16, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := New(header.NewAnswerHeader([2]byte{}, false, false), nil, nil, nil, nil)
+			if tt.edns {
+				msg.SetOPT(&record.OPT{UDPSize: 1232})
+			}
+			msg.SetResponseCode(tt.rcode)
+			parsed, err := Parse(msg.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsed.ResponseCode(); got != tt.rcode {
+				t.Errorf("Message.ResponseCode() = %d, want %d", got, tt.rcode)
+			}
+			if got := parsed.OPT() != nil; got != tt.wantOPT {
+				t.Errorf("Message.SetResponseCode() OPT present = %v, want %v", got, tt.wantOPT)
+			}
+		})
+	}
+}
+
+func TestMessage_Truncate_KeepsOPT(t *testing.T) {
+	q := []query.Query{query.New(label.Label{"example", "com"}, names.QTYPE(names.A))}
+	msg := New(header.NewAnswerHeader([2]byte{}, false, true), q, records(40), nil, records(5))
+	msg.SetOPT(&record.OPT{UDPSize: 512})
+	msg.Truncate(512)
+	if msg.OPT() == nil {
+		t.Error("Message.Truncate() removed the OPT record")
+	}
+	if len(msg.Additional) != 1 {
+		t.Errorf("Message.Truncate() left %d additional records, want 1", len(msg.Additional))
+	}
+	if !msg.Header.Truncated() {
+		t.Error("Message.Truncate() did not set TC")
+	}
+}
+
+func TestMessage_Encode_Compression(t *testing.T) {
+	name := label.Label{"example", "com"}
+	mail := label.Label{"mail", "example", "com"}
+	q := []query.Query{query.New(name, names.QTYPE(names.MX))}
+	answers := []response.Response{
+		response.FromRecord(name, 3600, &record.MX{Priority: 10, Name: mail}),
+		response.FromRecord(name, 3600, &record.MX{Priority: 20, Name: label.Label{"backup", "example", "net"}}),
+	}
+	authority := []response.Response{response.FromRecord(name, 3600, &record.NS{Label: label.Label{"ns", "example", "com"}})}
+	additional := []response.Response{response.FromRecord(mail, 3600, &record.A{IPv4: [4]byte{10, 0, 0, 1}})}
+
+	plain := New(header.NewAnswerHeader([2]byte{}, true, false), q, answers, authority, additional)
+	plain.Compress = false
+	compressed := New(header.NewAnswerHeader([2]byte{}, true, false), q, answers, authority, additional)
+
+	p, c := plain.Encode(), compressed.Encode()
+	if len(c) >= len(p) {
+		t.Errorf("compressed message has %d bytes, uncompressed %d", len(c), len(p))
+	}
+	// The name of the first answer directly follows the question and points to it
+	if c[29] != 0xC0 || c[30] != 12 {
+		t.Errorf("answer name is not compressed: %X", c[29:31])
+	}
+	for _, out := range [][]byte{p, c} {
+		parsed, err := Parse(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parsed.Answers[0].Record.(*record.MX).Name; !reflect.DeepEqual(got, mail) {
+			t.Errorf("MX exchange = %v, want %v", got, mail)
+		}
+		if got := parsed.Authorities[0].Record.(*record.NS).Label; !reflect.DeepEqual(got, label.Label{"ns", "example", "com"}) {
+			t.Errorf("NS = %v, want ns.example.com.", got)
+		}
+		if got := parsed.Additional[0].Name; !reflect.DeepEqual(got, mail) {
+			t.Errorf("additional name = %v, want %v", got, mail)
+		}
+	}
+}
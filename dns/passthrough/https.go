@@ -0,0 +1,163 @@
+package passthrough
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// MediaType is the media type of DNS messages sent via HTTPS
+const MediaType = "application/dns-message"
+
+// HTTPS is the DNS over HTTPS transport of RFC 8484. Queries are sent with the ID 0 so that HTTP caches can reuse answers.
+type HTTPS struct {
+	// URL is the URI template of the upstream without variables like https://cloudflare-dns.com/dns-query
+	URL string
+	// Client sends the requests. Its connections are reused, via HTTP/2 if the server supports it.
+	Client *http.Client
+	// GET sends queries via GET with the base64url encoded message in the dns parameter instead of POST
+	GET bool
+	// Timeout limits the time for a single request
+	Timeout time.Duration
+}
+
+// NewHTTPS returns an HTTPS transport to the upstream at url using config for TLS with the default settings
+func NewHTTPS(url string, config *tls.Config) *HTTPS {
+	t := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   config,
+		ForceAttemptHTTP2: true,
+		IdleConnTimeout:   DefaultIdleTimeout,
+	}
+	return &HTTPS{URL: url, Client: &http.Client{Transport: t}, Timeout: DefaultTimeout}
+}
+
+// Exchange sends the query via HTTPS. Answers with an HTTP error status fail like unreachable upstreams.
+// Record TTLs are reduced by the age of the answer and limited to its remaining freshness lifetime.
+func (t *HTTPS) Exchange(ctx context.Context, msg *message.Message) (*message.Message, error) {
+	sent := *msg
+	h := *msg.Header
+	h.ID = [2]byte{}
+	sent.Header = &h
+
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	req, err := t.request(ctx, sent.Encode())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint: errcheck
+	if resp.StatusCode/100 != 2 {
+		io.Copy(ioutil.Discard, resp.Body) //nolint: errcheck
+		return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, MediaType) {
+		return nil, fmt.Errorf("unexpected content type %s", ct)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	m, err := message.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if !valid(&sent, m, false) {
+		return nil, errMismatch
+	}
+	m.Header.ID = msg.Header.ID
+	if limit, ok := freshness(resp.Header); ok {
+		limitTTLs(m, limit)
+	}
+	return m, nil
+}
+
+// request returns the HTTP request carrying the encoded query
+func (t *HTTPS) request(ctx context.Context, query []byte) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if t.GET {
+		sep := "?"
+		if strings.Contains(t.URL, "?") {
+			sep = "&"
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, t.URL+sep+"dns="+base64.RawURLEncoding.EncodeToString(query), nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(query))
+		if err == nil {
+			req.Header.Set("Content-Type", MediaType)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", MediaType)
+	return req, nil
+}
+
+// ttlLimit bounds the TTLs of an answer received via HTTP
+type ttlLimit struct {
+	// age is the time the answer was kept in HTTP caches in seconds
+	age uint32
+	// maxAge is the freshness lifetime of the answer in seconds or -1 if it is unknown
+	maxAge int64
+}
+
+// freshness returns the age and freshness lifetime given by the Age and Cache-Control headers. ok is false if both are missing.
+func freshness(h http.Header) (limit ttlLimit, ok bool) {
+	limit.maxAge = -1
+	if v, err := strconv.ParseUint(strings.TrimSpace(h.Get("Age")), 10, 32); err == nil {
+		limit.age, ok = uint32(v), true
+	}
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.TrimSpace(strings.ToLower(d))
+		if strings.HasPrefix(d, "max-age=") {
+			if v, err := strconv.ParseInt(strings.TrimPrefix(d, "max-age="), 10, 64); err == nil && v >= 0 {
+				limit.maxAge, ok = v, true
+			}
+		}
+	}
+	return limit, ok
+}
+
+// limitTTLs reduces the TTLs of all records but OPT by the age of the answer and limits them to its remaining freshness lifetime
+func limitTTLs(m *message.Message, limit ttlLimit) {
+	remaining := int64(-1)
+	if limit.maxAge >= 0 {
+		remaining = limit.maxAge - int64(limit.age)
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+	for _, rs := range [][]response.Response{m.Answers, m.Authorities, m.Additional} {
+		for i := range rs {
+			if rs[i].Type == names.OPT {
+				continue
+			}
+			ttl := int64(rs[i].TTL) - int64(limit.age)
+			if ttl < 0 {
+				ttl = 0
+			}
+			if remaining >= 0 && ttl > remaining {
+				ttl = remaining
+			}
+			rs[i].TTL = uint32(ttl)
+		}
+	}
+}
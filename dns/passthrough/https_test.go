@@ -0,0 +1,143 @@
+package passthrough
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// startHTTPS runs a DNS over HTTPS stand-in server answering with status and cacheControl. It counts the connections it accepted.
+func startHTTPS(t *testing.T, status int, cacheControl string) (*httptest.Server, *int32) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("request used %s, want HTTP/2", r.Proto)
+		}
+		var data []byte
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			data, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		case http.MethodPost:
+			if r.Header.Get("Content-Type") != MediaType {
+				t.Errorf("request content type = %s", r.Header.Get("Content-Type"))
+			}
+			data, err = ioutil.ReadAll(r.Body)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := message.Parse(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Header.ID != [2]byte{} {
+			t.Errorf("query ID = %v, want 0", req.Header.ID)
+		}
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+			w.Header().Set("Age", "10")
+		}
+		w.Header().Set("Content-Type", MediaType)
+		w.Write(answer(req, dnserror.NoError, 1).Encode()) //nolint: errcheck
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+func TestHTTPS_Exchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		get          bool
+		status       int
+		cacheControl string
+		wantErr      bool
+		wantTTL      uint32
+	}{
+		{"POST", false, http.StatusOK, "", false, 60},
+		{"GET", true, http.StatusOK, "", false, 60},
+		{"Cache control", true, http.StatusOK, "public, max-age=40", false, 30},
+		{"Server error", false, http.StatusInternalServerError, "", true, 0},
+		{"Bad request", true, http.StatusBadRequest, "", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, conns := startHTTPS(t, tt.status, tt.cacheControl)
+			tr := &HTTPS{URL: srv.URL + "/dns-query", Client: srv.Client(), GET: tt.get, Timeout: DefaultTimeout}
+			for i := 0; i < 2; i++ {
+				msg := tlsQuery(1)
+				m, err := tr.Exchange(context.Background(), msg)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("HTTPS.Exchange() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					continue
+				}
+				if m.Header.ID != msg.Header.ID || m.Header.ResponseCode() != dnserror.NoError {
+					t.Fatalf("HTTPS.Exchange() = ID %v, RCode %d, want ID %v, RCode %d", m.Header.ID, m.Header.ResponseCode(), msg.Header.ID, dnserror.NoError)
+				}
+				if len(m.Answers) != 1 || m.Answers[0].TTL != tt.wantTTL {
+					t.Errorf("HTTPS.Exchange() answers = %v, want TTL %d", m.Answers, tt.wantTTL)
+				}
+			}
+			if n := atomic.LoadInt32(conns); n != 1 {
+				t.Errorf("server accepted %d connections, want 1", n)
+			}
+		})
+	}
+}
+
+func Test_limitTTLs(t *testing.T) {
+	tests := []struct {
+		name string
+		h    http.Header
+		ttl  uint32
+		want uint32
+	}{
+		{"No headers", http.Header{}, 300, 300},
+		{"Age", http.Header{"Age": {"100"}}, 300, 200},
+		{"Age exceeds TTL", http.Header{"Age": {"400"}}, 300, 0},
+		{"Max age", http.Header{"Cache-Control": {"max-age=60"}}, 300, 60},
+		{"Max age above TTL", http.Header{"Cache-Control": {"max-age=600"}}, 300, 300},
+		{"Max age and age", http.Header{"Cache-Control": {"no-transform, Max-Age=60"}, "Age": {"20"}}, 300, 40},
+		{"Stale", http.Header{"Cache-Control": {"max-age=60"}, "Age": {"90"}}, 300, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := message.New(header.NewQueryHeader(true), nil, []response.Response{{Type: names.A, TTL: tt.ttl}}, nil, []response.Response{{Type: names.OPT, TTL: 0x8000}})
+			if limit, ok := freshness(tt.h); ok {
+				limitTTLs(m, limit)
+			}
+			if m.Answers[0].TTL != tt.want {
+				t.Errorf("limitTTLs() TTL = %d, want %d", m.Answers[0].TTL, tt.want)
+			}
+			if m.Additional[0].TTL != 0x8000 {
+				t.Errorf("limitTTLs() changed the OPT record")
+			}
+		})
+	}
+}
@@ -1,89 +1,126 @@
 package passthrough
 
 import (
-	"fmt"
-	"net"
-	"os"
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/fossoreslp/go-dns/dns/error"
 	"github.com/fossoreslp/go-dns/dns/header"
 	"github.com/fossoreslp/go-dns/dns/message"
 	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-types"
 	"github.com/fossoreslp/go-dns/dns/response"
 )
 
-var cResolveRequest chan query.Query
-var cResolveResponse chan message.Message
+// DefaultUpstream is the upstream resolver used if none is configured
+const DefaultUpstream = "1.1.1.1:53"
 
-func init() {
-	cResolveRequest = make(chan query.Query)
-	cResolveResponse = make(chan message.Message)
-	go routine()
+// DefaultUDPSize is the payload size advertised to the upstream resolver via EDNS if none is configured
+const DefaultUDPSize = 1232
+
+// Resolver resolves queries with a list of upstream resolvers. Upstreams that fail to answer are taken out with an exponential backoff.
+type Resolver struct {
+	Upstreams []*Upstream
+	Policy    Policy
+	// UDPSize is the payload size advertised to the upstreams via EDNS
+	UDPSize int
+	// Log receives messages about upstreams being taken out and coming back. It may be nil.
+	Log *log.Logger
+
+	next uint32
 }
 
-// Routine is the function used as a goroutine to resolve a DNS request with CloudFlare
-func routine() {
-	cfdns, err := net.DialUDP("udp", nil, &net.UDPAddr{Port: 53, IP: net.ParseIP("1.1.1.1")})
-	if err != nil {
-		panic(err)
+// NewResolver returns a resolver using the upstreams given as host:port via UDP with the default settings
+func NewResolver(policy Policy, upstreams ...string) *Resolver {
+	if len(upstreams) == 0 {
+		upstreams = []string{DefaultUpstream}
 	}
-	defer cfdns.Close() //nolint: errcheck
-	var cfbuf [512]byte
-	for {
-		data := <-cResolveRequest
-		msg := message.New(header.NewQueryHeader(true), []query.Query{data}, nil, nil, nil)
-		_, err := cfdns.Write(msg.Encode())
-		if err != nil {
-			fmt.Println("Write failed with error:", err.Error(), "- trying to reconnect")
-			c, err := net.DialUDP("udp", nil, &net.UDPAddr{Port: 53, IP: net.ParseIP("1.1.1.1")})
-			if err != nil {
-				fmt.Println("Reconnect failed with error:", err.Error(), "- exiting")
-				os.Exit(-1)
-			}
-			_, err = c.Write(msg.Encode())
-			if err != nil {
-				fmt.Println("Reconnect successful but write still failed with error:", err.Error(), "- exiting")
-				os.Exit(-1)
-			}
-			fmt.Println("Reconnected successfully - replacing connection with newly established one")
-			cfdns = c
-		}
+	r := &Resolver{Policy: policy, UDPSize: DefaultUDPSize}
+	for _, addr := range upstreams {
+		r.Upstreams = append(r.Upstreams, &Upstream{Addr: addr, Transport: NewUDP(addr)})
+	}
+	return r
+}
 
-		n, err := cfdns.Read(cfbuf[:])
-		if err != nil {
-			fmt.Println("Read failed with error:", err.Error(), "- trying to reconnect")
-			c, err := net.DialUDP("udp", nil, &net.UDPAddr{Port: 53, IP: net.ParseIP("1.1.1.1")})
-			if err != nil {
-				fmt.Println("Reconnect failed with error:", err.Error(), "- exiting")
-				os.Exit(-1)
-			}
-			n, err = c.Read(cfbuf[:])
-			if err != nil {
-				fmt.Println("Reconnect successful but read still failed with error:", err.Error(), "- exiting")
-				os.Exit(-1)
-			}
-			fmt.Println("Reconnected successfully - replacing connection with newly established one")
-			cfdns = c
+// errMismatch is returned if the answer of an upstream does not belong to the query
+var errMismatch = errors.New("answer does not match the query")
+
+// Resolve resolves the query with the upstream resolvers. The available upstreams are tried in the order given by the policy until one answers.
+// SERVFAIL is returned if no upstream is available, all of them failed or ctx is done.
+func (r *Resolver) Resolve(ctx context.Context, q query.Query) ([]response.Response, dnserror.Error) {
+	rcode := dnserror.ServerFailure
+	for _, u := range r.order(time.Now()) {
+		start := time.Now()
+		msg := r.query(q)
+		m, err := u.Transport.Exchange(ctx, msg)
+		if err == nil && !valid(msg, m, false) {
+			err = errMismatch
+		}
+		if ctx.Err() != nil {
+			break
 		}
-		m, err := message.Parse(cfbuf[:n])
 		if err != nil {
-			fmt.Println("Failed to parse message:", err)
-			cResolveResponse <- message.Message{}
+			backoff := u.failed(time.Now())
+			r.logf("Upstream %s failed: %s. Retrying in %s.", u.Addr, err.Error(), backoff)
 			continue
 		}
-		cResolveResponse <- *m
+		if u.succeeded(time.Since(start)) {
+			r.logf("Upstream %s is available again", u.Addr)
+		}
+		switch rc := m.Header.ResponseCode(); rc {
+		case dnserror.NoError:
+			return m.Answers, dnserror.Success()
+		case dnserror.ServerFailure, dnserror.Refused:
+			// The upstream works but could not answer this query. Another one might.
+			rcode = rc
+		default:
+			return nil, dnserror.New(rc, m.Header.AuthoritativeAnswer())
+		}
 	}
+	return nil, dnserror.New(rcode, false)
 }
 
-// Resolve resolves the query with the external DNS provider
-func Resolve(q query.Query) ([]response.Response, dnserror.Error) {
-	cResolveRequest <- q
-	r := <-cResolveResponse
-	if r.Header == nil {
-		return nil, dnserror.New(dnserror.ServerFailure, false)
+// order returns the upstreams available at now in the order they should be tried in
+func (r *Resolver) order(now time.Time) []*Upstream {
+	avail := make([]*Upstream, 0, len(r.Upstreams))
+	for _, u := range r.Upstreams {
+		if u.Available(now) {
+			avail = append(avail, u)
+		}
+	}
+	if len(avail) < 2 {
+		return avail
+	}
+	switch r.Policy {
+	case RoundRobin:
+		i := int((atomic.AddUint32(&r.next, 1) - 1) % uint32(len(avail)))
+		avail = append(avail[i:], avail[:i]...)
+	case Random:
+		rand.Shuffle(len(avail), func(i, j int) { avail[i], avail[j] = avail[j], avail[i] })
+	case LowestRTT:
+		rtts := make(map[*Upstream]time.Duration, len(avail))
+		for _, u := range avail {
+			rtts[u] = u.RTT()
+		}
+		sort.SliceStable(avail, func(i, j int) bool { return rtts[avail[i]] < rtts[avail[j]] })
 	}
-	if r.Header.ResponseCode() != 0 {
-		return nil, dnserror.New(r.Header.ResponseCode(), r.Header.AuthoritativeAnswer())
+	return avail
+}
+
+// query returns the query message for q
+func (r *Resolver) query(q query.Query) *message.Message {
+	msg := message.New(header.NewQueryHeader(true), []query.Query{q}, nil, nil, nil)
+	msg.SetOPT(&record.OPT{UDPSize: uint16(r.UDPSize)})
+	return msg
+}
+
+func (r *Resolver) logf(format string, v ...interface{}) {
+	if r.Log != nil {
+		r.Log.Printf(format, v...)
 	}
-	return r.Answers, dnserror.Success()
 }
@@ -0,0 +1,354 @@
+package passthrough
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// serveUpstream runs a UDP resolver answering every query with the message returned by handle in its own goroutine. Queries are dropped if handle returns nil.
+func serveUpstream(t *testing.T, handle func(req *message.Message) *message.Message) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() }) //nolint: errcheck
+	go func() {
+		for {
+			buf := make([]byte, 512)
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req, err := message.Parse(buf[:n])
+			if err != nil {
+				continue
+			}
+			go func() {
+				if resp := handle(req); resp != nil {
+					conn.WriteTo(resp.Encode(), addr) //nolint: errcheck
+				}
+			}()
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// answer returns the answer to req with rcode and a single A record containing 10.0.0.ip
+func answer(req *message.Message, rcode uint8, ip byte) *message.Message {
+	var answers []response.Response
+	if rcode == dnserror.NoError {
+		answers = []response.Response{response.New(req.Questions[0].Name, names.A, 60, []byte{10, 0, 0, ip})}
+	}
+	h := header.NewAnswerHeader(req.Header.ID, false, true)
+	h.SetResponseCode(rcode)
+	return message.New(h, req.Questions, answers, nil, nil)
+}
+
+// startUpstream runs a UDP resolver answering every query with rcode and a single A record containing 10.0.0.ip
+func startUpstream(t *testing.T, rcode uint8, ip byte) string {
+	return serveUpstream(t, func(req *message.Message) *message.Message { return answer(req, rcode, ip) })
+}
+
+// setUDP changes the settings of the UDP transports of r
+func setUDP(r *Resolver, set func(t *UDP)) {
+	for _, u := range r.Upstreams {
+		set(u.Transport.(*UDP))
+	}
+}
+
+// deadUpstream returns an address nothing is listening on
+func deadUpstream(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close() //nolint: errcheck
+	return addr
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	q := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	tests := []struct {
+		name      string
+		upstreams func(t *testing.T) []string
+		wantIP    byte
+		wantRCode uint8
+		wantDown  []bool
+	}{
+		{"First answers", func(t *testing.T) []string {
+			return []string{startUpstream(t, dnserror.NoError, 1), startUpstream(t, dnserror.NoError, 2)}
+		}, 1, dnserror.NoError, []bool{false, false}},
+		{"Failover", func(t *testing.T) []string {
+			return []string{deadUpstream(t), startUpstream(t, dnserror.NoError, 2)}
+		}, 2, dnserror.NoError, []bool{true, false}},
+		{"Server failure tries next", func(t *testing.T) []string {
+			return []string{startUpstream(t, dnserror.ServerFailure, 1), startUpstream(t, dnserror.NoError, 2)}
+		}, 2, dnserror.NoError, []bool{false, false}},
+		{"Name error", func(t *testing.T) []string {
+			return []string{startUpstream(t, dnserror.NameError, 1), startUpstream(t, dnserror.NoError, 2)}
+		}, 0, dnserror.NameError, []bool{false, false}},
+		{"All down", func(t *testing.T) []string {
+			return []string{deadUpstream(t), deadUpstream(t)}
+		}, 0, dnserror.ServerFailure, []bool{true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(Failover, tt.upstreams(t)...)
+			setUDP(r, func(t *UDP) { t.Timeout = 250 * time.Millisecond })
+			resp, dnserr := r.Resolve(context.Background(), q)
+			if dnserr.RCode != tt.wantRCode {
+				t.Fatalf("Resolver.Resolve() rcode = %d, want %d", dnserr.RCode, tt.wantRCode)
+			}
+			if tt.wantIP != 0 && (len(resp) != 1 || !reflect.DeepEqual(resp[0].Data, []byte{10, 0, 0, tt.wantIP})) {
+				t.Errorf("Resolver.Resolve() = %v, want 10.0.0.%d", resp, tt.wantIP)
+			}
+			for i, u := range r.Upstreams {
+				if down := !u.Available(time.Now()); down != tt.wantDown[i] {
+					t.Errorf("upstream %d down = %v, want %v", i, down, tt.wantDown[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolver_Resolve_Recovery(t *testing.T) {
+	r := NewResolver(Failover, deadUpstream(t))
+	setUDP(r, func(t *UDP) { t.Timeout = 250 * time.Millisecond })
+	ctx := context.Background()
+	q := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	if _, dnserr := r.Resolve(ctx, q); dnserr.RCode != dnserror.ServerFailure {
+		t.Fatalf("Resolver.Resolve() rcode = %d, want SERVFAIL", dnserr.RCode)
+	}
+	// Replace the dead upstream by a working one keeping its health and let its backoff expire
+	u := r.Upstreams[0]
+	addr := startUpstream(t, dnserror.NoError, 1)
+	r.Upstreams[0] = &Upstream{Addr: addr, Transport: NewUDP(addr), failures: u.failures, retry: u.retry}
+	if _, dnserr := r.Resolve(ctx, q); dnserr.RCode != dnserror.ServerFailure {
+		t.Fatalf("Resolver.Resolve() used an upstream in backoff")
+	}
+	r.Upstreams[0].retry = time.Now()
+	if _, dnserr := r.Resolve(ctx, q); dnserr.IsError() {
+		t.Fatalf("Resolver.Resolve() rcode = %d after backoff expired", dnserr.RCode)
+	}
+	if r.Upstreams[0].failures != 0 {
+		t.Errorf("upstream still has %d failures", r.Upstreams[0].failures)
+	}
+}
+
+func TestResolver_Resolve_Concurrent(t *testing.T) {
+	// Every query is answered after a delay depending on its name so that answers arrive out of order
+	addr := serveUpstream(t, func(req *message.Message) *message.Message {
+		i, _ := strconv.Atoi(req.Questions[0].Name[0]) //nolint: errcheck
+		time.Sleep(time.Duration(20-i) * 10 * time.Millisecond)
+		return answer(req, dnserror.NoError, byte(i))
+	})
+	r := NewResolver(Failover, addr)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q := query.New(label.Label{strconv.Itoa(i), "example", "com"}, names.QTYPE(names.A))
+			resp, dnserr := r.Resolve(context.Background(), q)
+			if dnserr.IsError() || len(resp) != 1 || resp[0].Data[3] != byte(i) || resp[0].Name[0] != strconv.Itoa(i) {
+				t.Errorf("Resolver.Resolve(%s) = %v, %v", q.Name.String(), resp, dnserr)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("concurrent queries took %v, want them to be outstanding at the same time", d)
+	}
+}
+
+func TestResolver_Resolve_Retry(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[[2]byte]int)
+	// The first copy of every query is dropped
+	addr := serveUpstream(t, func(req *message.Message) *message.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[req.Header.ID]++
+		if seen[req.Header.ID] == 1 {
+			return nil
+		}
+		return answer(req, dnserror.NoError, 1)
+	})
+	q := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	tests := []struct {
+		name     string
+		attempts int
+		wantErr  bool
+	}{
+		{"Retried", 2, false},
+		{"No retries", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(Failover, addr)
+			setUDP(r, func(u *UDP) {
+				u.Timeout = 100 * time.Millisecond
+				u.Attempts = tt.attempts
+			})
+			if _, dnserr := r.Resolve(context.Background(), q); dnserr.IsError() != tt.wantErr {
+				t.Errorf("Resolver.Resolve() error = %v, wantErr %v", dnserr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolver_Resolve_Context(t *testing.T) {
+	addr := serveUpstream(t, func(req *message.Message) *message.Message { return nil })
+	r := NewResolver(Failover, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, dnserr := r.Resolve(ctx, query.New(label.Label{"example", "com"}, names.QTYPE(names.A)))
+	if dnserr.RCode != dnserror.ServerFailure || time.Since(start) > time.Second {
+		t.Errorf("Resolver.Resolve() = %v after %v, want SERVFAIL once the context is done", dnserr, time.Since(start))
+	}
+	if !r.Upstreams[0].Available(time.Now()) {
+		t.Error("upstream was taken out because the context was done")
+	}
+}
+
+func TestResolver_Resolve_0x20(t *testing.T) {
+	lower := func(req *message.Message) *message.Message {
+		m := answer(req, dnserror.NoError, 1)
+		m.Questions = []query.Query{query.New(label.Label{"www", "example", "com"}, names.QTYPE(names.A))}
+		return m
+	}
+	tests := []struct {
+		name    string
+		handle  func(req *message.Message) *message.Message
+		wantErr bool
+	}{
+		{"Case preserved", func(req *message.Message) *message.Message { return answer(req, dnserror.NoError, 1) }, false},
+		{"Case lost", lower, true},
+	}
+	q := query.New(label.Label{"www", "example", "com"}, names.QTYPE(names.A))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(Failover, serveUpstream(t, tt.handle))
+			setUDP(r, func(u *UDP) {
+				u.Timeout = 100 * time.Millisecond
+				u.Attempts = 1
+				u.Randomize0x20 = true
+			})
+			resp, dnserr := r.Resolve(context.Background(), q)
+			if dnserr.IsError() != tt.wantErr {
+				t.Fatalf("Resolver.Resolve() error = %v, wantErr %v", dnserr, tt.wantErr)
+			}
+			if !tt.wantErr && (len(resp) != 1 || resp[0].Name.String() != q.Name.String()) {
+				t.Errorf("Resolver.Resolve() = %v, want the owner name in its original case", resp)
+			}
+		})
+	}
+}
+
+func Test_randomizeCase(t *testing.T) {
+	l := label.Label{"abcdefghijklmnopqrstuvwxyz", "_tcp-0", "example"}
+	changed := false
+	for i := 0; i < 10 && !changed; i++ {
+		got := randomizeCase(l)
+		if !strings.EqualFold(got.String(), l.String()) {
+			t.Fatalf("randomizeCase() = %s, want %s in any case", got.String(), l.String())
+		}
+		changed = got.String() != l.String()
+	}
+	if !changed {
+		t.Error("randomizeCase() never changed the case")
+	}
+}
+
+func TestResolver_order(t *testing.T) {
+	now := time.Now()
+	newResolver := func(p Policy) *Resolver {
+		r := NewResolver(p, "a:53", "b:53", "c:53", "d:53")
+		r.Upstreams[0].rtt = 30 * time.Millisecond
+		r.Upstreams[1].failed(now)
+		r.Upstreams[2].rtt = 10 * time.Millisecond
+		return r
+	}
+	addrs := func(us []*Upstream) []string {
+		out := make([]string, len(us))
+		for i, u := range us {
+			out[i] = u.Addr
+		}
+		return out
+	}
+	tests := []struct {
+		policy Policy
+		want   [][]string
+	}{
+		{Failover, [][]string{{"a:53", "c:53", "d:53"}, {"a:53", "c:53", "d:53"}}},
+		{RoundRobin, [][]string{{"a:53", "c:53", "d:53"}, {"c:53", "d:53", "a:53"}, {"d:53", "a:53", "c:53"}}},
+		{LowestRTT, [][]string{{"d:53", "c:53", "a:53"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			r := newResolver(tt.policy)
+			for i, want := range tt.want {
+				if got := addrs(r.order(now)); !reflect.DeepEqual(got, want) {
+					t.Errorf("Resolver.order() call %d = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+	t.Run("random", func(t *testing.T) {
+		got := addrs(newResolver(Random).order(now))
+		if len(got) != 3 {
+			t.Errorf("Resolver.order() = %v, want 3 available upstreams", got)
+		}
+	})
+}
+
+func TestUpstream_failed(t *testing.T) {
+	now := time.Now()
+	u := &Upstream{Addr: "a:53"}
+	want := []time.Duration{MinBackoff, 2 * MinBackoff, 4 * MinBackoff}
+	for i, w := range want {
+		if got := u.failed(now); got != w {
+			t.Errorf("Upstream.failed() call %d = %v, want %v", i, got, w)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		u.failed(now)
+	}
+	if got := u.failed(now); got != MaxBackoff {
+		t.Errorf("Upstream.failed() = %v, want %v", got, MaxBackoff)
+	}
+	if u.Available(now.Add(MaxBackoff - time.Second)) {
+		t.Error("Upstream.Available() = true during backoff")
+	}
+	if !u.Available(now.Add(MaxBackoff)) {
+		t.Error("Upstream.Available() = false after backoff")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, p := range []Policy{Failover, RoundRobin, Random, LowestRTT} {
+		if got, err := ParsePolicy(p.String()); err != nil || got != p {
+			t.Errorf("ParsePolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if _, err := ParsePolicy("fastest"); err == nil {
+		t.Error("ParsePolicy(\"fastest\") succeeded")
+	}
+}
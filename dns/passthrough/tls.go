@@ -0,0 +1,281 @@
+package passthrough
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/stream"
+)
+
+// DefaultIdleTimeout is the time an unused TLS connection is kept open if none is configured
+const DefaultIdleTimeout = 30 * time.Second
+
+// TLS is the DNS over TLS transport of RFC 7858. Queries share a persistent connection and are pipelined as described in RFC 7766.
+// A new connection is opened once the previous one failed or was idle for IdleTimeout.
+type TLS struct {
+	// Addr is the address of the upstream as host:port
+	Addr string
+	// Config is used for the TLS handshake. It has to set the ServerName unless verification is done by VerifyPeerCertificate only.
+	Config *tls.Config
+	// Timeout limits the time for connecting and for the exchange of a single query
+	Timeout time.Duration
+	// IdleTimeout is the time after which a connection without outstanding queries is closed
+	IdleTimeout time.Duration
+
+	mu   sync.Mutex
+	conn *tlsConn
+}
+
+// NewTLS returns a TLS transport to the upstream at addr using config with the default settings
+func NewTLS(addr string, config *tls.Config) *TLS {
+	return &TLS{Addr: addr, Config: config, Timeout: DefaultTimeout, IdleTimeout: DefaultIdleTimeout}
+}
+
+// TLSConfig returns the configuration for connecting to an upstream named serverName.
+// The certificate is verified with the CAs in the PEM file caFile or the system roots if it is empty.
+// If pins are given, the connection is only accepted if one of the certificates has a public key whose base64 encoded SHA-256 digest (RFC 7469) is in pins.
+// Without caFile, pins replace the verification of the certificate chain like the out-of-band key-pinned profile of RFC 7858.
+func TLSConfig(serverName, caFile string, pins []string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", caFile)
+		}
+	}
+	if len(pins) == 0 {
+		return config, nil
+	}
+	pinned := make(map[string]bool, len(pins))
+	for _, p := range pins {
+		if d, err := base64.StdEncoding.DecodeString(p); err != nil || len(d) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %s", p)
+		}
+		pinned[p] = true
+	}
+	config.InsecureSkipVerify = caFile == ""
+	config.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+		for _, c := range raw {
+			cert, err := x509.ParseCertificate(c)
+			if err != nil {
+				return err
+			}
+			digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if pinned[base64.StdEncoding.EncodeToString(digest[:])] {
+				return nil
+			}
+		}
+		return errors.New("no certificate matches the SPKI pins")
+	}
+	return config, nil
+}
+
+// Exchange sends the query over the shared connection and waits for its answer.
+// A query sent over a reused connection is repeated once over a new connection if the old one failed.
+func (t *TLS) Exchange(ctx context.Context, msg *message.Message) (*message.Message, error) {
+	c, reused, err := t.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.exchange(ctx, msg, t.Timeout)
+	if err == nil || !reused || ctx.Err() != nil || !c.failed() {
+		return m, err
+	}
+	// The upstream may have closed the connection while the query was sent
+	if c, _, err = t.connection(ctx); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, msg, t.Timeout)
+}
+
+// connection returns the open connection or dials a new one. reused reports whether the connection was used before.
+func (t *TLS) connection(ctx context.Context) (c *tlsConn, reused bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil && !t.conn.failed() {
+		return t.conn, true, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	d := &tls.Dialer{Config: t.Config}
+	nc, err := d.DialContext(ctx, "tcp", t.Addr)
+	if err != nil {
+		return nil, false, err
+	}
+	t.conn = newTLSConn(nc, t.IdleTimeout)
+	return t.conn, false, nil
+}
+
+// Close closes the connection. Outstanding queries fail.
+func (t *TLS) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		t.conn.close(errClosed)
+		t.conn = nil
+	}
+	return nil
+}
+
+// errClosed is returned for queries outstanding when the connection is closed
+var errClosed = errors.New("connection closed")
+
+// tlsConn is a connection carrying pipelined queries. Answers are matched by ID and question and may arrive in any order.
+type tlsConn struct {
+	c    net.Conn
+	wmu  sync.Mutex
+	idle time.Duration
+
+	mu      sync.Mutex
+	pending map[[2]byte]*outstanding
+	err     error
+	timer   *time.Timer
+}
+
+// outstanding is a query waiting for its answer
+type outstanding struct {
+	msg *message.Message
+	ch  chan result
+}
+
+type result struct {
+	msg *message.Message
+	err error
+}
+
+func newTLSConn(c net.Conn, idle time.Duration) *tlsConn {
+	tc := &tlsConn{c: c, idle: idle, pending: make(map[[2]byte]*outstanding)}
+	tc.timer = time.AfterFunc(idle, tc.closeIdle)
+	go tc.read()
+	return tc
+}
+
+// exchange sends msg with an ID unique on the connection and waits at most timeout for the answer. The answer carries the ID of msg.
+func (c *tlsConn) exchange(ctx context.Context, msg *message.Message, timeout time.Duration) (*message.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	sent := *msg
+	h := *msg.Header
+	sent.Header = &h
+	o := &outstanding{msg: &sent, ch: make(chan result, 1)}
+	if err := c.register(o); err != nil {
+		return nil, err
+	}
+	defer c.unregister(o)
+
+	c.wmu.Lock()
+	c.c.SetWriteDeadline(time.Now().Add(timeout)) //nolint: errcheck
+	err := stream.Write(c.c, sent.Encode())
+	c.wmu.Unlock()
+	if err != nil {
+		c.close(err)
+		return nil, err
+	}
+	select {
+	case r := <-o.ch:
+		if r.err != nil {
+			return nil, r.err
+		}
+		r.msg.Header.ID = msg.Header.ID
+		return r.msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// register adds o to the outstanding queries and changes its ID if it is already in use
+func (c *tlsConn) register(o *outstanding) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	for c.pending[o.msg.Header.ID] != nil {
+		o.msg.Header.ID = header.NewQueryHeader(true).ID
+	}
+	c.pending[o.msg.Header.ID] = o
+	c.timer.Stop()
+	return nil
+}
+
+// unregister removes o from the outstanding queries and starts the idle timer once no query is outstanding
+func (c *tlsConn) unregister(o *outstanding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[o.msg.Header.ID] == o {
+		delete(c.pending, o.msg.Header.ID)
+	}
+	if len(c.pending) == 0 && c.err == nil {
+		c.timer.Reset(c.idle)
+	}
+}
+
+// read delivers the answers received on the connection until reading fails
+func (c *tlsConn) read() {
+	for {
+		data, err := stream.Read(c.c)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		m, err := message.Parse(data)
+		if err != nil {
+			continue
+		}
+		c.mu.Lock()
+		o := c.pending[m.Header.ID]
+		if o != nil && valid(o.msg, m, false) {
+			delete(c.pending, m.Header.ID)
+			o.ch <- result{msg: m}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// closeIdle closes the connection if no query is outstanding
+func (c *tlsConn) closeIdle() {
+	c.mu.Lock()
+	idle := len(c.pending) == 0
+	c.mu.Unlock()
+	if idle {
+		c.close(errClosed)
+	}
+}
+
+// close closes the connection after it failed with err and fails all outstanding queries
+func (c *tlsConn) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.timer.Stop()
+	c.c.Close() //nolint: errcheck
+	for id, o := range c.pending {
+		o.ch <- result{err: err}
+		delete(c.pending, id)
+	}
+}
+
+// failed reports whether the connection can no longer be used
+func (c *tlsConn) failed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
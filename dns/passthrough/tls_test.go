@@ -0,0 +1,261 @@
+package passthrough
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/stream"
+)
+
+// testCertificate returns a self-signed certificate for dns.test and 127.0.0.1 together with the path of its PEM file and its SPKI pin
+func testCertificate(t *testing.T) (cert tls.Certificate, caFile, pin string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dns.test"},
+		DNSNames:              []string{"dns.test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile = filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(parsed.RawSubjectPublicKeyInfo)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile, base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// tlsUpstream is a DNS over TLS stand-in server. It answers queries in batches of size in reverse order to test pipelining.
+type tlsUpstream struct {
+	addr     string
+	accepted int32
+	closed   chan struct{}
+}
+
+func startTLSUpstream(t *testing.T, cert tls.Certificate, batch int) *tlsUpstream {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() }) //nolint: errcheck
+	u := &tlsUpstream{addr: l.Addr().String(), closed: make(chan struct{}, 10)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&u.accepted, 1)
+			go u.serve(c, batch)
+		}
+	}()
+	return u
+}
+
+func (u *tlsUpstream) serve(c net.Conn, batch int) {
+	defer func() { u.closed <- struct{}{} }()
+	defer c.Close() //nolint: errcheck
+	var reqs []*message.Message
+	for {
+		data, err := stream.Read(c)
+		if err != nil {
+			return
+		}
+		req, err := message.Parse(data)
+		if err != nil {
+			return
+		}
+		if reqs = append(reqs, req); len(reqs) < batch {
+			continue
+		}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			stream.Write(c, answer(reqs[i], dnserror.NoError, byte(len(reqs[i].Questions[0].Name[0]))).Encode()) //nolint: errcheck
+		}
+		reqs = nil
+	}
+}
+
+// tlsQuery returns a query for a name whose first label has n characters. The stand-in server answers it with 10.0.0.n.
+func tlsQuery(n int) *message.Message {
+	name := label.Label{strings.Repeat("a", n), "example", "com"}
+	return message.New(header.NewQueryHeader(true), []query.Query{query.New(name, names.QTYPE(names.A))}, nil, nil, nil)
+}
+
+func TestTLS_Exchange(t *testing.T) {
+	cert, caFile, pin := testCertificate(t)
+	_, otherCA, otherPin := testCertificate(t)
+	u := startTLSUpstream(t, cert, 1)
+	tests := []struct {
+		name       string
+		serverName string
+		caFile     string
+		pins       []string
+		wantErr    bool
+	}{
+		{"CA", "dns.test", caFile, nil, false},
+		{"CA and pin", "dns.test", caFile, []string{otherPin, pin}, false},
+		{"Pin only", "", "", []string{pin}, false},
+		{"Other name", "other.test", caFile, nil, true},
+		{"Other CA", "dns.test", otherCA, nil, true},
+		{"Other pin", "dns.test", caFile, []string{otherPin}, true},
+		{"System roots", "dns.test", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := TLSConfig(tt.serverName, tt.caFile, tt.pins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := NewTLS(u.addr, config)
+			defer tr.Close() //nolint: errcheck
+			msg := tlsQuery(3)
+			m, err := tr.Exchange(context.Background(), msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TLS.Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (m.Header.ID != msg.Header.ID || len(m.Answers) != 1 || m.Answers[0].Data[3] != 3) {
+				t.Errorf("TLS.Exchange() = %v", m)
+			}
+		})
+	}
+}
+
+func TestTLS_Exchange_Pipelining(t *testing.T) {
+	cert, caFile, _ := testCertificate(t)
+	u := startTLSUpstream(t, cert, 4)
+	config, err := TLSConfig("dns.test", caFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTLS(u.addr, config)
+	defer tr.Close() //nolint: errcheck
+	// The server only answers once all four queries arrived on the same connection
+	var wg sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			msg := tlsQuery(i)
+			m, err := tr.Exchange(ctx, msg)
+			if err != nil || m.Header.ID != msg.Header.ID || len(m.Answers) != 1 || m.Answers[0].Data[3] != byte(i) {
+				t.Errorf("TLS.Exchange(%d) = %v, %v", i, m, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&u.accepted); n != 1 {
+		t.Errorf("server accepted %d connections, want 1", n)
+	}
+}
+
+func TestTLS_Exchange_IdleTimeout(t *testing.T) {
+	cert, caFile, _ := testCertificate(t)
+	u := startTLSUpstream(t, cert, 1)
+	config, err := TLSConfig("dns.test", caFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTLS(u.addr, config)
+	tr.IdleTimeout = 50 * time.Millisecond
+	defer tr.Close() //nolint: errcheck
+	for i := 0; i < 2; i++ {
+		if _, err := tr.Exchange(context.Background(), tlsQuery(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&u.accepted); n != 1 {
+		t.Fatalf("server accepted %d connections, want the connection to be reused", n)
+	}
+	select {
+	case <-u.closed:
+	case <-time.After(time.Second):
+		t.Fatal("idle connection was not closed")
+	}
+	if _, err := tr.Exchange(context.Background(), tlsQuery(1)); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&u.accepted); n != 2 {
+		t.Errorf("server accepted %d connections, want a new one after the idle timeout", n)
+	}
+}
+
+func TestTLS_Exchange_Timeout(t *testing.T) {
+	cert, caFile, _ := testCertificate(t)
+	// The server reads the query but waits for more before answering
+	u := startTLSUpstream(t, cert, 100)
+	config, err := TLSConfig("dns.test", caFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTLS(u.addr, config)
+	tr.Timeout = 100 * time.Millisecond
+	defer tr.Close() //nolint: errcheck
+	start := time.Now()
+	if _, err := tr.Exchange(context.Background(), tlsQuery(1)); err == nil {
+		t.Fatal("TLS.Exchange() succeeded without an answer")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("TLS.Exchange() returned after %s, want the timeout of %s", d, tr.Timeout)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		caFile  string
+		pins    []string
+		wantErr bool
+	}{
+		{"Defaults", "", nil, false},
+		{"Missing CA file", "/nonexistent/ca.pem", nil, true},
+		{"Invalid pin", "", []string{"abc"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := TLSConfig("dns.test", tt.caFile, tt.pins); (err != nil) != tt.wantErr {
+				t.Errorf("TLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
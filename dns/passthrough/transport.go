@@ -0,0 +1,72 @@
+package passthrough
+
+import (
+	"context"
+	crand "crypto/rand"
+	"strings"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// DefaultTimeout is the time waited for an answer from a single upstream if none is configured
+const DefaultTimeout = 2 * time.Second
+
+// Transport sends queries to a single upstream resolver
+type Transport interface {
+	// Exchange sends the query msg and returns the answer. The answer has the ID and question of msg.
+	// Exchange may be called concurrently and gives up once ctx is done.
+	Exchange(ctx context.Context, msg *message.Message) (*message.Message, error)
+}
+
+// valid reports whether m is the answer to the query msg. Names have to match exactly if exact is set.
+func valid(msg, m *message.Message, exact bool) bool {
+	return m.Header.ID == msg.Header.ID && m.Header.IsResponse() && matches(msg.Questions[0], m.Questions, exact)
+}
+
+// matches reports whether qs is exactly the question q. The case of the name is ignored unless exact is set.
+func matches(q query.Query, qs []query.Query, exact bool) bool {
+	if len(qs) != 1 || qs[0].Type != q.Type || qs[0].Class != q.Class {
+		return false
+	}
+	if exact {
+		return qs[0].Name.String() == q.Name.String()
+	}
+	return strings.EqualFold(qs[0].Name.String(), q.Name.String())
+}
+
+// randomizeCase returns a copy of l with the case of every letter chosen at random
+func randomizeCase(l label.Label) label.Label {
+	out := make(label.Label, len(l))
+	for i, s := range l {
+		b := []byte(s)
+		bits := make([]byte, len(b))
+		crand.Read(bits) //nolint: errcheck
+		for j, c := range b {
+			if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+				if bits[j]&1 == 1 {
+					b[j] = c ^ 0x20
+				}
+			}
+		}
+		out[i] = string(b)
+	}
+	return out
+}
+
+// restoreCase replaces the randomised name sent in records of m by the name originally asked for
+func restoreCase(m *message.Message, sent, orig label.Label) {
+	for i := range m.Questions {
+		m.Questions[i].Name = orig
+	}
+	for _, rs := range [][]response.Response{m.Answers, m.Authorities, m.Additional} {
+		for i := range rs {
+			if rs[i].Name.String() == sent.String() {
+				rs[i].Name = orig
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package passthrough
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/stream"
+)
+
+// DefaultAttempts is the number of times a query is sent via UDP if none is configured
+const DefaultAttempts = 2
+
+// errTimeout is returned if no answer arrived in time
+var errTimeout = errors.New("upstream timed out")
+
+// UDP is the plain DNS transport. Queries are sent via UDP and repeated via TCP if the answer was truncated.
+// Every query is sent from its own socket with a random source port and answers are only accepted if their source, ID and question match the query.
+type UDP struct {
+	// Addr is the address of the upstream as host:port
+	Addr string
+	// Size is the size of the buffer answers are read into
+	Size int
+	// Timeout is the time waited for an answer before the query is sent again
+	Timeout time.Duration
+	// Attempts is the number of times a query is sent before giving up
+	Attempts int
+	// Randomize0x20 randomises the case of query names and only accepts answers echoing it as described in draft-vixie-dnsext-dns0x20.
+	// Upstreams that do not preserve the case of the question fail every query.
+	Randomize0x20 bool
+
+	mu   sync.Mutex
+	addr *net.UDPAddr
+}
+
+// NewUDP returns a UDP transport to the upstream at addr with the default settings
+func NewUDP(addr string) *UDP {
+	return &UDP{Addr: addr, Size: DefaultUDPSize, Timeout: DefaultTimeout, Attempts: DefaultAttempts}
+}
+
+// Exchange sends the query via UDP and repeats it via TCP if the answer was truncated
+func (t *UDP) Exchange(ctx context.Context, msg *message.Message) (*message.Message, error) {
+	addr, err := t.resolve()
+	if err != nil {
+		return nil, err
+	}
+	attempts := t.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	sent := *msg
+	q := msg.Questions[0]
+	if t.Randomize0x20 {
+		sent.Questions = []query.Query{q}
+		sent.Questions[0].Name = randomizeCase(q.Name)
+	}
+	m, err := exchangeUDP(ctx, addr, sent.Encode(), t.Size, t.Timeout, attempts, func(m *message.Message) bool {
+		return valid(&sent, m, t.Randomize0x20)
+	})
+	if err == nil && m.Header.Truncated() {
+		m, err = t.exchangeTCP(ctx, &sent)
+	}
+	if err == nil && t.Randomize0x20 {
+		restoreCase(m, sent.Questions[0].Name, q.Name)
+	}
+	return m, err
+}
+
+// resolve returns the UDP address of the upstream. It is only looked up once.
+func (t *UDP) resolve() (*net.UDPAddr, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.addr == nil {
+		addr, err := net.ResolveUDPAddr("udp", t.Addr)
+		if err != nil {
+			return nil, err
+		}
+		t.addr = addr
+	}
+	return t.addr, nil
+}
+
+// exchangeTCP sends the query to the upstream via TCP
+func (t *UDP) exchangeTCP(ctx context.Context, msg *message.Message) (*message.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", t.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close() //nolint: errcheck
+	deadline, _ := ctx.Deadline()
+	c.SetDeadline(deadline) //nolint: errcheck
+	if err := stream.Write(c, msg.Encode()); err != nil {
+		return nil, err
+	}
+	data, err := stream.Read(c)
+	if err != nil {
+		return nil, err
+	}
+	m, err := message.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if !valid(msg, m, t.Randomize0x20) {
+		return nil, errMismatch
+	}
+	return m, nil
+}
+
+// exchangeUDP sends msg to addr from a new socket with a random source port and waits for the answer.
+// Datagrams are only accepted as the answer if they come from addr and accept returns true for them. All others are ignored.
+// The query is sent again every timeout until attempts is reached or ctx is done.
+func exchangeUDP(ctx context.Context, addr *net.UDPAddr, msg []byte, size int, timeout time.Duration, attempts int, accept func(*message.Message) bool) (*message.Message, error) {
+	network := "udp4"
+	if addr.IP.To4() == nil {
+		network = "udp6"
+	}
+	// Port 0 lets the system pick a random ephemeral port for every query
+	c, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close() //nolint: errcheck
+
+	answers := make(chan *message.Message, 1)
+	go func() {
+		buf := make([]byte, size)
+		for {
+			n, from, err := c.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !from.IP.Equal(addr.IP) || from.Port != addr.Port {
+				continue
+			}
+			m, err := message.Parse(buf[:n])
+			if err != nil || !accept(m) {
+				continue
+			}
+			answers <- m
+			return
+		}
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for i := 1; ; i++ {
+		if _, err := c.WriteToUDP(msg, addr); err != nil {
+			return nil, err
+		}
+		select {
+		case m := <-answers:
+			return m, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+			if i >= attempts {
+				return nil, errTimeout
+			}
+			t.Reset(timeout)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package passthrough
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+)
+
+func TestResolver_Resolve_Spoofed(t *testing.T) {
+	spoofer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spoofer.Close() //nolint: errcheck
+	other := query.New(label.Label{"example", "org"}, names.QTYPE(names.A))
+	tests := []struct {
+		name  string
+		spoof func(conn net.PacketConn, req *message.Message) (net.PacketConn, *message.Message)
+	}{
+		{"Other source", func(conn net.PacketConn, req *message.Message) (net.PacketConn, *message.Message) {
+			return spoofer, answer(req, dnserror.NoError, 66)
+		}},
+		{"Other ID", func(conn net.PacketConn, req *message.Message) (net.PacketConn, *message.Message) {
+			m := answer(req, dnserror.NoError, 66)
+			m.Header.ID[0] ^= 1
+			return conn, m
+		}},
+		{"Other question", func(conn net.PacketConn, req *message.Message) (net.PacketConn, *message.Message) {
+			m := answer(req, dnserror.NoError, 66)
+			m.Questions = []query.Query{other}
+			return conn, m
+		}},
+		{"Query instead of answer", func(conn net.PacketConn, req *message.Message) (net.PacketConn, *message.Message) {
+			return conn, req
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close() //nolint: errcheck
+			// The forged answer arrives before the genuine one
+			go func() {
+				buf := make([]byte, 512)
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				req, err := message.Parse(buf[:n])
+				if err != nil {
+					return
+				}
+				from, forged := tt.spoof(conn, req)
+				from.WriteTo(forged.Encode(), addr) //nolint: errcheck
+				time.Sleep(20 * time.Millisecond)
+				conn.WriteTo(answer(req, dnserror.NoError, 1).Encode(), addr) //nolint: errcheck
+			}()
+			r := NewResolver(Failover, conn.LocalAddr().String())
+			setUDP(r, func(u *UDP) { u.Attempts = 1 })
+			resp, dnserr := r.Resolve(context.Background(), query.New(label.Label{"example", "com"}, names.QTYPE(names.A)))
+			if dnserr.IsError() || len(resp) != 1 || resp[0].Data[3] != 1 {
+				t.Errorf("Resolver.Resolve() = %v, %v, want the genuine answer", resp, dnserr)
+			}
+		})
+	}
+}
+
+func TestResolver_Resolve_SourcePort(t *testing.T) {
+	var mu sync.Mutex
+	ports := make(map[int]bool)
+	addr := serveUpstream(t, func(req *message.Message) *message.Message { return answer(req, dnserror.NoError, 1) })
+	// Wrap the upstream to record the source ports of the queries
+	proxy, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close() //nolint: errcheck
+	go func() {
+		upstream, _ := net.ResolveUDPAddr("udp", addr) //nolint: errcheck
+		buf := make([]byte, 512)
+		for {
+			n, from, err := proxy.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			ports[from.(*net.UDPAddr).Port] = true
+			mu.Unlock()
+			c, err := net.DialUDP("udp", nil, upstream)
+			if err != nil {
+				return
+			}
+			c.Write(buf[:n]) //nolint: errcheck
+			n, err = c.Read(buf)
+			c.Close() //nolint: errcheck
+			if err == nil {
+				proxy.WriteTo(buf[:n], from) //nolint: errcheck
+			}
+		}
+	}()
+	r := NewResolver(Failover, proxy.LocalAddr().String())
+	q := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	for i := 0; i < 3; i++ {
+		if _, dnserr := r.Resolve(context.Background(), q); dnserr.IsError() {
+			t.Fatalf("Resolver.Resolve() error = %v", dnserr)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ports) < 2 {
+		t.Errorf("queries were sent from ports %v, want a new port per query", ports)
+	}
+}
@@ -0,0 +1,113 @@
+package passthrough
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Policy decides the order in which the available upstream resolvers are tried
+type Policy int
+
+const (
+	// Failover always tries the upstreams in the configured order
+	Failover Policy = iota
+	// RoundRobin starts with the next upstream for every query
+	RoundRobin
+	// Random tries the upstreams in random order
+	Random
+	// LowestRTT tries the upstreams with the lowest round-trip time first. Upstreams without measurements are tried first.
+	LowestRTT
+)
+
+var policyNames = map[Policy]string{
+	Failover:   "failover",
+	RoundRobin: "round-robin",
+	Random:     "random",
+	LowestRTT:  "lowest-rtt",
+}
+
+func (p Policy) String() string {
+	if s, ok := policyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy returns the policy named s. An empty name selects Failover.
+func ParsePolicy(s string) (Policy, error) {
+	if s == "" {
+		return Failover, nil
+	}
+	for p, name := range policyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown upstream policy %s", s)
+}
+
+const (
+	// MinBackoff is the time a failed upstream is taken out for after its first failure
+	MinBackoff = time.Second
+	// MaxBackoff limits the time a failed upstream is taken out for
+	MaxBackoff = 5 * time.Minute
+)
+
+// Upstream is an upstream resolver together with its health
+type Upstream struct {
+	// Addr is the address of the resolver as shown in log messages
+	Addr string
+	// Transport is used to send queries to the resolver
+	Transport Transport
+
+	mu       sync.Mutex
+	failures int
+	retry    time.Time
+	rtt      time.Duration
+}
+
+// Available reports whether the upstream may be used at now. Failed upstreams become available again once their backoff expired.
+func (u *Upstream) Available(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.failures == 0 || !now.Before(u.retry)
+}
+
+// RTT returns the smoothed round-trip time of the upstream or zero if it was not measured yet
+func (u *Upstream) RTT() time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.rtt
+}
+
+// succeeded marks the upstream as healthy and records the round-trip time of an answer.
+// It reports whether the upstream was taken out before.
+func (u *Upstream) succeeded(rtt time.Duration) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.rtt == 0 {
+		u.rtt = rtt
+	} else {
+		u.rtt = (7*u.rtt + rtt) / 8
+	}
+	recovered := u.failures > 0
+	u.failures = 0
+	return recovered
+}
+
+// failed takes the upstream out until now plus a backoff that doubles with every consecutive failure and returns the backoff
+func (u *Upstream) failed(now time.Time) time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	backoff := MinBackoff
+	for i := 0; i < u.failures && backoff < MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxBackoff {
+		backoff = MaxBackoff
+	}
+	u.failures++
+	u.retry = now.Add(backoff)
+	return backoff
+}
@@ -28,11 +28,11 @@ func Parse(message []byte, number uint16) ([]Query, int, error) {
 	}
 	pos := 12
 	for ; number > 0; number-- {
-		labels, moved, err := label.GetLabelsFromMessage(message, pos)
+		labels, end, err := label.GetLabelsFromMessage(message, pos)
 		if err != nil {
 			return nil, 0, err
 		}
-		pos += moved
+		pos = end
 		if len(message) < pos+4 {
 			return nil, 0, errors.New("message too short for QTYPE and QCLASS")
 		}
@@ -51,7 +51,13 @@ func New(name label.Label, t names.QTYPE) Query {
 
 // Encode returns the query in DNS message format
 func (q Query) Encode() []byte {
-	b := q.Name.Encode()
+	return q.EncodeCompressed(nil, 0)
+}
+
+// EncodeCompressed returns the query in DNS message format for offset off of a message using the compression table c.
+// If c is nil, no compression is used.
+func (q Query) EncodeCompressed(c label.Compression, off int) []byte {
+	b := q.Name.EncodeCompressed(c, off)
 	a := make([]byte, 4)
 	binary.BigEndian.PutUint16(a[:2], uint16(q.Type))
 	binary.BigEndian.PutUint16(a[2:], uint16(q.Class))
@@ -0,0 +1,588 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+)
+
+// maxIncludeDepth limits the nesting of $INCLUDE directives
+const maxIncludeDepth = 16
+
+// maxGenerate limits the number of records a single $GENERATE directive may create
+const maxGenerate = 65536
+
+// ParseError describes an error in a master file
+type ParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err.Error())
+}
+
+// ParseMasterFile parses the master file (RFC 1035 section 5) at path and returns a set containing the zone described by it.
+// origin is the name of the zone. If it is empty, the first $ORIGIN directive or the owner of the first record is used.
+func ParseMasterFile(path, origin string) (*Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint: errcheck
+	return ParseMaster(f, path, origin)
+}
+
+// ParseMaster parses a master file read from r. file is used in error messages and relative $INCLUDE paths are resolved relative to it.
+func ParseMaster(r io.Reader, file, origin string) (*Set, error) {
+	p := &masterParser{entries: make(map[string]Entry), implicit: make(map[string]map[names.TYPE]bool)}
+	f := &masterFile{p: p, file: file}
+	if origin != "" {
+		o, err := parseName(strings.TrimSuffix(origin, ".")+".", nil)
+		if err != nil {
+			return nil, &ParseError{file, 0, err}
+		}
+		f.origin = o
+		p.zone = o
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.parse(string(data)); err != nil {
+		return nil, err
+	}
+	if p.zone == nil {
+		return nil, &ParseError{file, 0, errors.New("master file does not contain any records")}
+	}
+	z := Zone{Exclusive: true, TTL: p.ttl, Entries: p.entries}
+	ttl := z.DefaultTTL()
+	for key, types := range p.implicit {
+		for t, ok := range types {
+			if ok {
+				z.Entries[key][t] = RRSet{ttl, z.Entries[key][t].Records}
+			}
+		}
+	}
+	return &Set{concat(p.zone): z}, nil
+}
+
+// masterParser holds the state shared by a master file and all files included by it
+type masterParser struct {
+	zone    label.Label
+	entries map[string]Entry
+	// ttl is the value of the last $TTL directive and hasTTL reports whether there was one
+	ttl    uint32
+	hasTTL bool
+	// last is the last TTL stated explicitly in a record and hasLast reports whether there was one
+	last    uint32
+	hasLast bool
+	// implicit marks the RRsets without an explicit TTL. They use the default TTL of the zone which is only known after parsing the SOA record.
+	implicit map[string]map[names.TYPE]bool
+}
+
+// add stores rec as a record of owner. If the records of an RRset have different TTLs, the lowest one is used.
+func (p *masterParser) add(owner label.Label, rec record.Record, ttl uint32, explicit bool) error {
+	if p.zone == nil {
+		p.zone = owner
+	}
+	if len(owner) < len(p.zone) || concat(owner[len(owner)-len(p.zone):]) != concat(p.zone) {
+		return fmt.Errorf("%s is outside of zone %s", owner.String(), p.zone.String())
+	}
+	key := Apex
+	if len(owner) > len(p.zone) {
+		key = concat(owner[:len(owner)-len(p.zone)])
+	}
+	e, ok := p.entries[key]
+	if !ok {
+		e = make(Entry)
+		p.entries[key] = e
+		p.implicit[key] = make(map[names.TYPE]bool)
+	}
+	// Records without a TTL use the $TTL in effect or else the last explicitly stated TTL (RFC 1035 section 5.1)
+	switch {
+	case explicit:
+		p.last, p.hasLast = ttl, true
+	case p.hasTTL:
+		ttl, explicit = p.ttl, true
+	case p.hasLast:
+		ttl, explicit = p.last, true
+	}
+	t := rec.Type()
+	rs, ok := e[t]
+	switch {
+	case !ok:
+		rs.TTL = ttl
+		p.implicit[key][t] = !explicit
+	case explicit && p.implicit[key][t]:
+		rs.TTL = ttl
+		p.implicit[key][t] = false
+	case explicit && ttl < rs.TTL:
+		rs.TTL = ttl
+	}
+	for _, r := range rs.Records {
+		if r.String() == rec.String() {
+			e[t] = rs
+			return nil
+		}
+	}
+	rs.Records = append(rs.Records, rec)
+	e[t] = rs
+	return checkCNAME(e)
+}
+
+// masterFile holds the state of a single master file
+type masterFile struct {
+	p      *masterParser
+	file   string
+	depth  int
+	origin label.Label
+	owner  label.Label
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// masterLine is a logical line of a master file. Lines joined by parentheses form a single logical line.
+type masterLine struct {
+	tokens   []token
+	indented bool
+	number   int
+}
+
+// parse processes the contents of a master file
+func (f *masterFile) parse(data string) error {
+	lines, err := lex(data)
+	if err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			pe.File = f.file
+		}
+		return err
+	}
+	for _, l := range lines {
+		if err := f.entry(l); err != nil {
+			if _, ok := err.(*ParseError); ok {
+				return err
+			}
+			return &ParseError{f.file, l.number, err}
+		}
+	}
+	return nil
+}
+
+// entry processes a single directive or record
+func (f *masterFile) entry(l masterLine) error {
+	t := l.tokens
+	if l.indented || t[0].quoted || !strings.HasPrefix(t[0].text, "$") {
+		return f.record(t, l.indented)
+	}
+	switch strings.ToUpper(t[0].text) {
+	case "$ORIGIN":
+		if len(t) != 2 {
+			return errors.New("$ORIGIN requires exactly one domain name")
+		}
+		o, err := f.name(t[1].text)
+		if err != nil {
+			return err
+		}
+		f.origin = o
+		if f.p.zone == nil {
+			f.p.zone = o
+		}
+		return nil
+	case "$TTL":
+		if len(t) != 2 {
+			return errors.New("$TTL requires exactly one TTL")
+		}
+		ttl, err := parseTTL(t[1].text)
+		if err != nil {
+			return err
+		}
+		f.p.ttl, f.p.hasTTL = ttl, true
+		return nil
+	case "$INCLUDE":
+		return f.include(t[1:])
+	case "$GENERATE":
+		return f.generate(t[1:])
+	default:
+		return fmt.Errorf("unknown directive %s", t[0].text)
+	}
+}
+
+// record processes a resource record. If indented is true, the record belongs to the previous owner.
+func (f *masterFile) record(t []token, indented bool) error {
+	if !indented {
+		o, err := f.name(t[0].text)
+		if err != nil {
+			return err
+		}
+		f.owner = o
+		t = t[1:]
+	}
+	if f.owner == nil {
+		return errors.New("record without owner name")
+	}
+	var ttl uint32
+	explicit := false
+	for len(t) > 0 {
+		s := strings.ToUpper(t[0].text)
+		if s == "IN" {
+			t = t[1:]
+			continue
+		}
+		if _, ok := names.ClassToInt(s); ok {
+			return fmt.Errorf("class %s is not supported", t[0].text)
+		}
+		if v, err := parseTTL(t[0].text); err == nil && !explicit {
+			ttl, explicit = v, true
+			t = t[1:]
+			continue
+		}
+		break
+	}
+	if len(t) == 0 {
+		return errors.New("record type missing")
+	}
+	typ, ok := names.TypeToInt(strings.ToUpper(t[0].text))
+	rec := record.New(names.TYPE(typ))
+	if !ok || rec == nil || names.TYPE(typ) == names.OPT {
+		return fmt.Errorf("unsupported record type %s", t[0].text)
+	}
+	data, err := f.rdata(names.TYPE(typ), t[1:])
+	if err != nil {
+		return err
+	}
+	if err := rec.Parse(data); err != nil {
+		return fmt.Errorf("invalid %s record: %s", t[0].text, err.Error())
+	}
+	return f.p.add(f.owner, rec, ttl, explicit)
+}
+
+// nameFields lists the positions of domain names in the record data of each type
+var nameFields = map[names.TYPE][]int{
+	names.CNAME: {0},
+	names.NS:    {0},
+	names.PTR:   {0},
+	names.MX:    {1},
+	names.SOA:   {0, 1},
+	names.SRV:   {3},
+}
+
+// timeFields lists the positions of fields that may use time units in the record data of each type
+var timeFields = map[names.TYPE][]int{
+	names.SOA: {3, 4, 5, 6},
+}
+
+// rdata turns the record data tokens into the presentation format accepted by the records' Parse methods
+func (f *masterFile) rdata(t names.TYPE, tokens []token) (string, error) {
+	parts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		if tok.quoted || t == names.TXT {
+			parts[i] = "\"" + tok.text + "\""
+		} else {
+			parts[i] = tok.text
+		}
+	}
+	for _, i := range nameFields[t] {
+		if i >= len(tokens) || tokens[i].quoted {
+			continue
+		}
+		n, err := f.name(tokens[i].text)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = n.String()
+	}
+	for _, i := range timeFields[t] {
+		if i >= len(tokens) {
+			continue
+		}
+		v, err := parseTTL(tokens[i].text)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = strconv.FormatUint(uint64(v), 10)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// name resolves a possibly relative domain name using the current origin
+func (f *masterFile) name(s string) (label.Label, error) {
+	return parseName(s, f.origin)
+}
+
+// parseName parses a domain name. Names not ending in a dot are relative to origin and @ refers to origin itself.
+func parseName(s string, origin label.Label) (label.Label, error) {
+	absolute := strings.HasSuffix(s, ".")
+	if !absolute && origin == nil {
+		return nil, fmt.Errorf("relative name %s used without origin", s)
+	}
+	if s == "@" {
+		return origin, nil
+	}
+	if s == "." {
+		return label.Label{}, nil
+	}
+	l, err := label.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %s: %s", s, err.Error())
+	}
+	if absolute {
+		return l, nil
+	}
+	out := make(label.Label, 0, len(l)+len(origin))
+	return append(append(out, l...), origin...), nil
+}
+
+// include processes an $INCLUDE directive. Changes of the origin or owner inside the included file do not affect the including file.
+func (f *masterFile) include(t []token) error {
+	if len(t) < 1 || len(t) > 2 {
+		return errors.New("$INCLUDE requires a file name and an optional origin")
+	}
+	if f.depth >= maxIncludeDepth {
+		return errors.New("too many nested $INCLUDE directives")
+	}
+	path := t[0].text
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(f.file), path)
+	}
+	origin := f.origin
+	if len(t) == 2 {
+		o, err := f.name(t[1].text)
+		if err != nil {
+			return err
+		}
+		origin = o
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	inc := &masterFile{p: f.p, file: path, depth: f.depth + 1, origin: origin}
+	return inc.parse(string(data))
+}
+
+// generate processes a $GENERATE directive as supported by BIND: $GENERATE start-stop[/step] lhs [ttl] [class] type rhs
+func (f *masterFile) generate(t []token) error {
+	if len(t) < 4 {
+		return errors.New("$GENERATE requires a range, owner, type and data")
+	}
+	start, stop, step, err := parseRange(t[0].text)
+	if err != nil {
+		return err
+	}
+	if (stop-start)/step >= maxGenerate {
+		return fmt.Errorf("$GENERATE may not create more than %d records", maxGenerate)
+	}
+	for i := start; i <= stop; i += step {
+		lhs, err := substitute(t[1].text, i)
+		if err != nil {
+			return err
+		}
+		rhs, err := substitute(t[len(t)-1].text, i)
+		if err != nil {
+			return err
+		}
+		rec := append([]token{{lhs, false}}, t[2:len(t)-1]...)
+		rec = append(rec, token{rhs, t[len(t)-1].quoted})
+		if err := f.record(rec, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRange parses the range of a $GENERATE directive
+func parseRange(s string) (start, stop, step int, err error) {
+	step = 1
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		if step, err = strconv.Atoi(s[i+1:]); err != nil || step < 1 {
+			return 0, 0, 0, fmt.Errorf("invalid step in range %s", s)
+		}
+		s = s[:i]
+	}
+	b := strings.SplitN(s, "-", 2)
+	if len(b) != 2 {
+		return 0, 0, 0, fmt.Errorf("range %s has to be in format start-stop", s)
+	}
+	if start, err = strconv.Atoi(b[0]); err != nil || start < 0 {
+		return 0, 0, 0, fmt.Errorf("invalid start of range %s", s)
+	}
+	if stop, err = strconv.Atoi(b[1]); err != nil || stop < start {
+		return 0, 0, 0, fmt.Errorf("invalid end of range %s", s)
+	}
+	return start, stop, step, nil
+}
+
+// substitute replaces $ in a $GENERATE template with i. ${offset[,width[,base]]} modifies the value and \$ is a literal $.
+func substitute(tmpl string, i int) (string, error) {
+	var b strings.Builder
+	for n := 0; n < len(tmpl); n++ {
+		c := tmpl[n]
+		if c == '\\' && n+1 < len(tmpl) && tmpl[n+1] == '$' {
+			b.WriteByte('$')
+			n++
+			continue
+		}
+		if c != '$' {
+			b.WriteByte(c)
+			continue
+		}
+		if n+1 >= len(tmpl) || tmpl[n+1] != '{' {
+			b.WriteString(strconv.Itoa(i))
+			continue
+		}
+		end := strings.IndexByte(tmpl[n:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated modifier in %s", tmpl)
+		}
+		mod := strings.Split(tmpl[n+2:n+end], ",")
+		if len(mod) > 3 {
+			return "", fmt.Errorf("invalid modifier in %s", tmpl)
+		}
+		offset, width, base := 0, 0, "d"
+		var err error
+		if offset, err = strconv.Atoi(mod[0]); err != nil {
+			return "", fmt.Errorf("invalid offset in %s", tmpl)
+		}
+		if len(mod) > 1 {
+			if width, err = strconv.Atoi(mod[1]); err != nil || width < 0 {
+				return "", fmt.Errorf("invalid width in %s", tmpl)
+			}
+		}
+		if len(mod) > 2 {
+			base = mod[2]
+		}
+		switch base {
+		case "d", "o", "x", "X":
+			fmt.Fprintf(&b, "%0*"+base, width, i+offset)
+		default:
+			return "", fmt.Errorf("unsupported base %s in %s", base, tmpl)
+		}
+		n += end
+	}
+	return b.String(), nil
+}
+
+// parseTTL parses a TTL given in seconds or using the units w, d, h, m and s (e.g. 1h30m)
+func parseTTL(s string) (uint32, error) {
+	if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(v), nil
+	}
+	if s == "" {
+		return 0, errors.New("empty TTL")
+	}
+	var total, cur uint64
+	digits := false
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			cur = cur*10 + uint64(c-'0')
+			digits = true
+			if cur > 1<<32 {
+				return 0, fmt.Errorf("TTL %s out of range", s)
+			}
+			continue
+		}
+		unit, ok := map[rune]uint64{'w': 604800, 'd': 86400, 'h': 3600, 'm': 60, 's': 1}[c]
+		if !ok || !digits {
+			return 0, fmt.Errorf("invalid TTL %s", s)
+		}
+		total += cur * unit
+		cur, digits = 0, false
+	}
+	if digits {
+		return 0, fmt.Errorf("invalid TTL %s", s)
+	}
+	if total > 1<<32-1 {
+		return 0, fmt.Errorf("TTL %s out of range", s)
+	}
+	return uint32(total), nil
+}
+
+// lex splits the contents of a master file into logical lines of tokens
+func lex(data string) ([]masterLine, error) { // nolint: gocyclo
+	var lines []masterLine
+	cur := masterLine{number: 1}
+	number, depth := 1, 0
+	lineStart := true
+	flush := func() {
+		if len(cur.tokens) > 0 {
+			lines = append(lines, cur)
+		}
+		cur = masterLine{number: number}
+	}
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '\n':
+			number++
+			i++
+			if depth == 0 {
+				flush()
+				lineStart = true
+			}
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			if lineStart {
+				cur.indented = true
+			}
+			i++
+		case c == ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth == 0 {
+				return nil, &ParseError{Line: number, Err: errors.New("closing parenthesis without opening one")}
+			}
+			depth--
+			i++
+		case c == '"':
+			start := number
+			j := i + 1
+			for ; j < len(data) && data[j] != '"'; j++ {
+				if data[j] == '\\' {
+					j++
+				}
+				if j < len(data) && data[j] == '\n' {
+					number++
+				}
+			}
+			if j >= len(data) {
+				return nil, &ParseError{Line: start, Err: errors.New("unterminated quoted string")}
+			}
+			cur.tokens = append(cur.tokens, token{data[i+1 : j], true})
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(data) && !strings.ContainsRune(" \t\r\n;()\"", rune(data[j])); j++ {
+				if data[j] == '\\' && j+1 < len(data) {
+					j++
+				}
+			}
+			cur.tokens = append(cur.tokens, token{data[i:j], false})
+			i = j
+		}
+		lineStart = false
+	}
+	if depth > 0 {
+		return nil, &ParseError{Line: cur.number, Err: errors.New("unbalanced parentheses")}
+	}
+	flush()
+	return lines, nil
+}
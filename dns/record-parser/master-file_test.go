@@ -0,0 +1,239 @@
+package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+)
+
+const testZone = `; Example zone
+$ORIGIN example.com.
+$TTL 1h
+@	IN	SOA	ns1 hostmaster (
+		2018010101 ; serial
+		1d         ; refresh
+		2h         ; retry
+		4w         ; expire
+		300 )      ; minimum
+	IN	NS	ns1
+	IN	NS	ns2.example.net.
+	IN	MX	10 mail
+	3600 IN	TXT	"v=spf1 mx -all"
+ns1	A	10.0.0.1
+mail	IN	300	A	10.0.0.2
+	AAAA	fd00::2
+www	CNAME	@
+text	TXT	"quoted \"string\"" two words "semi;colon"
+_sip._tcp	SRV	10 20 5060 sip
+sip	A	10.0.0.3
+caa	CAA	0 issue "letsencrypt.org"
+$ORIGIN sub.example.com.
+host	A	10.0.1.1
+$GENERATE 1-3 dyn-$ A 10.0.2.$
+$GENERATE 10-20/5 ${0,3,d}.rev PTR host-${1,2,x}
+`
+
+func records(t *testing.T, set *Set, zone, entry string, typ names.TYPE) []string {
+	z, ok := (*set)[zone]
+	if !ok {
+		t.Fatalf("zone %s not found", zone)
+	}
+	var out []string
+	for _, r := range z.Entries[entry][typ].Records {
+		out = append(out, r.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestParseMaster(t *testing.T) {
+	set, err := ParseMaster(strings.NewReader(testZone), "example.com.zone", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*set) != 1 {
+		t.Fatalf("ParseMaster() returned %d zones, want 1", len(*set))
+	}
+	tests := []struct {
+		entry string
+		typ   names.TYPE
+		want  []string
+	}{
+		{Apex, names.SOA, []string{"ns1.example.com. hostmaster.example.com. 2018010101 86400 7200 2419200 300"}},
+		{Apex, names.NS, []string{"ns1.example.com.", "ns2.example.net."}},
+		{Apex, names.TXT, []string{`"v=spf1 mx -all"`}},
+		{"ns1", names.A, []string{"10.0.0.1"}},
+		{"mail", names.A, []string{"10.0.0.2"}},
+		{"mail", names.AAAA, []string{(&record.AAAA{IPv6: [16]byte{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}}).String()}},
+		{"www", names.CNAME, []string{"example.com."}},
+		{"text", names.TXT, []string{`"quoted \"string\"" "two" "words" "semi;colon"`}},
+		{"_sip._tcp", names.SRV, []string{"10 20 5060 sip.example.com."}},
+		{"caa", names.CAA, []string{`0 issue "letsencrypt.org"`}},
+		{"host.sub", names.A, []string{"10.0.1.1"}},
+		{"dyn-1.sub", names.A, []string{"10.0.2.1"}},
+		{"dyn-3.sub", names.A, []string{"10.0.2.3"}},
+		{"010.rev.sub", names.PTR, []string{"host-0b.sub.example.com."}},
+		{"020.rev.sub", names.PTR, []string{"host-15.sub.example.com."}},
+	}
+	for _, tt := range tests {
+		typ, _ := names.IntToType(uint16(tt.typ))
+		t.Run(tt.entry+"/"+typ, func(t *testing.T) {
+			if got := records(t, set, "example.com", tt.entry, tt.typ); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMaster() %s = %v, want %v", tt.entry, got, tt.want)
+			}
+		})
+	}
+	mx := (*set)["example.com"].Entries[Apex][names.MX].Records[0].(*record.MX)
+	if mx.Priority != 10 || !reflect.DeepEqual(mx.Name, label.Label{"mail", "example", "com"}) {
+		t.Errorf("ParseMaster() MX = %v", mx)
+	}
+	if _, ok := (*set)["example.com"].Entries["dyn-4.sub"]; ok {
+		t.Error("ParseMaster() $GENERATE exceeded its range")
+	}
+}
+
+func TestParseMaster_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		origin   string
+		wantLine int
+		wantErr  string
+	}{
+		{"Relative name without origin", "www A 10.0.0.1\n", "", 1, "without origin"},
+		{"Outside of zone", "www A 10.0.0.1\nwww.example.org. A 10.0.0.2\n", "example.com", 2, "outside of zone"},
+		{"Unknown type", "\n\nwww FOO bar\n", "example.com", 3, "unsupported record type"},
+		{"Unsupported class", "www CH A 10.0.0.1\n", "example.com", 1, "class CH"},
+		{"Invalid data", "www A 10.0.0\n", "example.com", 1, "invalid A record"},
+		{"Missing type", "www 300 IN\n", "example.com", 1, "type missing"},
+		{"Unknown directive", "$FOO bar\n", "example.com", 1, "unknown directive"},
+		{"Unbalanced parentheses", "@ SOA ns hm (\n1 2 3 4 5\n", "example.com", 1, "unbalanced"},
+		{"Closing parenthesis", "www A 10.0.0.1 )\n", "example.com", 1, "closing parenthesis"},
+		{"Unterminated string", "www TXT \"abc\n", "example.com", 1, "unterminated"},
+		{"Error after multi-line record", "@ SOA ns hm (\n1 2 3 4 5 )\nwww A x\n", "example.com", 3, "invalid A record"},
+		{"No owner", " A 10.0.0.1\n", "example.com", 1, "without owner"},
+		{"Invalid TTL", "$TTL 1y\n", "example.com", 1, "invalid TTL"},
+		{"Invalid range", "$GENERATE 5-1 host-$ A 10.0.0.$\n", "example.com", 1, "invalid end of range"},
+		{"Empty", "; nothing here\n", "", 0, "does not contain any records"},
+		{"CNAME and other data", "www A 10.0.0.1\nwww CNAME @\n", "example.com", 2, "must not be combined"},
+		{"Data next to CNAME", "www CNAME @\n  TXT text\n", "example.com", 2, "must not be combined"},
+		{"Multiple CNAME records", "www CNAME a\nwww CNAME b\n", "example.com", 2, "single CNAME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMaster(strings.NewReader(tt.input), "test.zone", tt.origin)
+			pe, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("ParseMaster() error = %v, want *ParseError", err)
+			}
+			if pe.File != "test.zone" || pe.Line != tt.wantLine {
+				t.Errorf("ParseMaster() error position = %s:%d, want test.zone:%d", pe.File, pe.Line, tt.wantLine)
+			}
+			if !strings.Contains(pe.Error(), tt.wantErr) {
+				t.Errorf("ParseMaster() error = %q, want it to contain %q", pe.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseMasterFile_Include(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"example.com.zone": "$ORIGIN example.com.\n@ NS ns\n$INCLUDE hosts.zone\n$INCLUDE sub.zone sub.example.com.\nafter A 10.0.0.9\n",
+		"hosts.zone":       "ns A 10.0.0.1\n$ORIGIN other.example.com.\nhost A 10.0.0.2\n",
+		"sub.zone":         "@ A 10.0.1.1\nbroken A 10.0.1\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := ParseMasterFile(filepath.Join(dir, "example.com.zone"), "")
+	pe, ok := err.(*ParseError)
+	if !ok || pe.File != filepath.Join(dir, "sub.zone") || pe.Line != 2 {
+		t.Fatalf("ParseMasterFile() error = %v, want error in sub.zone line 2", err)
+	}
+
+	files["sub.zone"] = "@ A 10.0.1.1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub.zone"), []byte(files["sub.zone"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := ParseMasterFile(filepath.Join(dir, "example.com.zone"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for entry, want := range map[string]string{"ns": "10.0.0.1", "host.other": "10.0.0.2", "sub": "10.0.1.1", "after": "10.0.0.9"} {
+		if got := records(t, set, "example.com", entry, names.A); !reflect.DeepEqual(got, []string{want}) {
+			t.Errorf("ParseMasterFile() %s = %v, want %s", entry, got, want)
+		}
+	}
+}
+
+func Test_parseTTL(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uint32
+		wantErr bool
+	}{
+		{"3600", 3600, false},
+		{"1h", 3600, false},
+		{"1h30m", 5400, false},
+		{"1W2D", 777600, false},
+		{"", 0, true},
+		{"h", 0, true},
+		{"10x", 0, true},
+		{"10h5", 0, true},
+		{"4294967296", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTTL(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTTL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMaster_TTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]uint32
+	}{
+		{"Explicit", "@ 300 A 10.0.0.1\nwww IN 1h A 10.0.0.2\n", map[string]uint32{Apex: 300, "www": 3600}},
+		{"Last explicit", "@ 300 A 10.0.0.1\nwww A 10.0.0.2\n", map[string]uint32{Apex: 300, "www": 300}},
+		{"$TTL before last explicit", "$TTL 600\n@ 300 A 10.0.0.1\nwww A 10.0.0.2\n", map[string]uint32{Apex: 300, "www": 600}},
+		{"$TTL", "$TTL 600\n@ A 10.0.0.1\nwww 60 A 10.0.0.2\n", map[string]uint32{Apex: 600, "www": 60}},
+		{"SOA minimum", "@ SOA ns hm 1 2 3 4 120\nwww A 10.0.0.2\n", map[string]uint32{"www": 120}},
+		{"SOA minimum after record", "www A 10.0.0.2\n@ SOA ns hm 1 2 3 4 120\n", map[string]uint32{"www": 120}},
+		{"Default", "www A 10.0.0.2\n", map[string]uint32{"www": DefaultTTL}},
+		{"Lowest TTL of RRset", "www 300 A 10.0.0.1\nwww 60 A 10.0.0.2\nwww 600 A 10.0.0.3\n", map[string]uint32{"www": 60}},
+		{"Explicit TTL in RRset", "www A 10.0.0.1\nwww 600 A 10.0.0.2\n", map[string]uint32{"www": 600}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParseMaster(strings.NewReader(tt.input), "test.zone", "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := (*set)["example.com"].Entries
+			for owner, want := range tt.want {
+				if got := e[owner][names.A].TTL; got != want {
+					t.Errorf("ParseMaster() TTL of %s = %d, want %d", owner, got, want)
+				}
+			}
+		})
+	}
+}
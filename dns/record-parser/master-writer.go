@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fossoreslp/go-dns/dns/record-names"
+)
+
+// WriteMaster writes all zones in set to w in master file format. Zones are sorted by name and each one starts with an $ORIGIN directive.
+func WriteMaster(w io.Writer, set *Set) error {
+	zones := make([]string, 0, len(*set))
+	for name := range *set {
+		zones = append(zones, name)
+	}
+	sort.Slice(zones, func(i, j int) bool { return canonicalLess(zones[i], zones[j]) })
+	for i, name := range zones {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		z := (*set)[name]
+		if err := WriteZone(w, name, &z); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteZone writes zone to w in master file format using name as the origin. Every record is written with its TTL.
+// Owner names are written relative to the origin in canonical order (RFC 4034 section 6.1), the SOA record is written first.
+func WriteZone(w io.Writer, name string, zone *Zone) error {
+	origin := strings.TrimSuffix(name, ".") + "."
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s\n", origin); err != nil {
+		return err
+	}
+	if zone.TTL != 0 {
+		if _, err := fmt.Fprintf(w, "$TTL %d\n", zone.TTL); err != nil {
+			return err
+		}
+	}
+	owners := make([]string, 0, len(zone.Entries))
+	for owner := range zone.Entries {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return canonicalLess(owners[i], owners[j]) })
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, owner := range owners {
+		e := zone.Entries[owner]
+		for _, t := range sortedTypes(e) {
+			typ, ok := names.IntToType(uint16(t))
+			if !ok {
+				return fmt.Errorf("unknown record type %d at %s", t, owner)
+			}
+			for _, r := range e[t].Records {
+				if _, err := fmt.Fprintf(tw, "%s\t%d\tIN\t%s\t%s\n", owner, e[t].TTL, typ, r.String()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// sortedTypes returns the types present in e ordered by their value with SOA first
+func sortedTypes(e Entry) []names.TYPE {
+	types := make([]names.TYPE, 0, len(e))
+	for t, rs := range e {
+		if len(rs.Records) > 0 {
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i] == names.SOA || types[j] == names.SOA {
+			return types[i] == names.SOA
+		}
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// canonicalLess reports whether the relative or absolute name a sorts before b. Names are compared label by label starting with the rightmost one.
+func canonicalLess(a, b string) bool {
+	la, lb := splitName(a), splitName(b)
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		x, y := la[len(la)-i], lb[len(lb)-i]
+		if x != y {
+			return x < y
+		}
+	}
+	return len(la) < len(lb)
+}
+
+func splitName(s string) []string {
+	s = strings.ToLower(strings.TrimSuffix(s, "."))
+	if s == "" || s == Apex {
+		return nil
+	}
+	return strings.Split(s, ".")
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+)
+
+func TestWriteZone(t *testing.T) {
+	zone := &Zone{Exclusive: true, TTL: 3600, Entries: map[string]Entry{
+		"www": {names.A: {300, []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, 2}}}}},
+		Apex: {
+			names.MX:  {3600, []record.Record{&record.MX{Priority: 10, Name: label.Label{"mail", "example", "com"}}}},
+			names.SOA: {3600, []record.Record{&record.SOA{MName: label.Label{"ns", "example", "com"}, RName: label.Label{"hostmaster", "example", "com"}, Serial: 1, Refresh: 2, Retry: 3, Expire: 4, Minimum: 5}}},
+			names.NS:  {86400, []record.Record{&record.NS{Label: label.Label{"ns", "example", "com"}}}},
+		},
+		"a.www": {names.TXT: {60, []record.Record{&record.TXT{Strings: []string{"hello world", `"quoted"`}}}}},
+		"mail":  {names.AAAA: {3600, []record.Record{&record.AAAA{IPv6: [16]byte{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}}}}},
+	}}
+	want := `$ORIGIN example.com.
+$TTL 3600
+@     3600  IN SOA  ns.example.com. hostmaster.example.com. 1 2 3 4 5
+@     86400 IN NS   ns.example.com.
+@     3600  IN MX   10 mail.example.com.
+mail  3600  IN AAAA fd00::1
+www   300   IN A    10.0.0.2
+a.www 60    IN TXT  "hello world" "\"quoted\""
+`
+	var b bytes.Buffer
+	if err := WriteZone(&b, "example.com", zone); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Errorf("WriteZone() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteMaster_RoundTrip(t *testing.T) {
+	set, err := ParseMaster(strings.NewReader(testZone), "example.com.zone", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteMaster(&b, set); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseMaster(&b, "written.zone", "")
+	if err != nil {
+		t.Fatalf("parsing written zone failed: %v\n%s", err, b.String())
+	}
+	if !reflect.DeepEqual(got, set) {
+		t.Errorf("WriteMaster() did not round-trip:\n%s", b.String())
+	}
+}
+
+func Test_canonicalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{Apex, "www", true},
+		{"www", Apex, false},
+		{"www", "a.www", true},
+		{"a.www", "b", false},
+		{"A.example.com.", "b.example.com", true},
+		{"z.a", "a.b", true},
+		{"com", "com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := canonicalLess(tt.a, tt.b); got != tt.want {
+				t.Errorf("canonicalLess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// FormatTOML is the format of zones.toml
+	FormatTOML = "toml"
+	// FormatMaster is the master file format of RFC 1035
+	FormatMaster = "master"
+)
+
+// Source describes a file or directory zones are loaded from
+type Source struct {
+	// Path is the file or directory containing the zones. Every master file in a directory contains the zone named after the file without a .zone or .db extension.
+	// TOML files in a directory name their zones themselves.
+	Path string
+	// Format is either FormatTOML or FormatMaster. If it is empty, files ending in .toml are read as TOML and all others as master files.
+	Format string
+	// Origin is the name of the zone in a master file or the name the zones in a TOML file are relative to. It is ignored for directories.
+	Origin string
+	// Optional sources are skipped if Path does not exist
+	Optional bool
+}
+
+func (s Source) String() string {
+	if s.Origin == "" {
+		return s.Path
+	}
+	return s.Origin + "=" + s.Path
+}
+
+// Load reads all sources and merges them into a single set. It fails if a zone is defined by more than one source.
+func Load(sources ...Source) (*Set, error) {
+	set := make(Set)
+	origins := make(map[string]string)
+	for _, s := range sources {
+		loaded, err := s.Load()
+		if os.IsNotExist(err) && s.Optional {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := merge(set, *loaded, origins, s.Path); err != nil {
+			return nil, err
+		}
+	}
+	return &set, nil
+}
+
+// Load reads the zones of the source
+func (s Source) Load() (*Set, error) {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return s.loadFile(s.Path, s.Origin)
+	}
+	files, err := ioutil.ReadDir(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	set := make(Set)
+	origins := make(map[string]string)
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(s.Path, f.Name())
+		// Master files are named after their zone while TOML files name their zones themselves
+		origin := ""
+		if s.format(path) == FormatMaster {
+			origin = strings.TrimSuffix(strings.TrimSuffix(f.Name(), ".zone"), ".db")
+		}
+		loaded, err := s.loadFile(path, origin)
+		if err != nil {
+			return nil, err
+		}
+		if err := merge(set, *loaded, origins, path); err != nil {
+			return nil, err
+		}
+	}
+	return &set, nil
+}
+
+// format returns the format of the file at path. Unless the source sets a format, files ending in .toml are TOML files and all others master files.
+func (s Source) format(path string) string {
+	if s.Format != "" {
+		return s.Format
+	}
+	if strings.HasSuffix(path, ".toml") {
+		return FormatTOML
+	}
+	return FormatMaster
+}
+
+// loadFile reads a single file of the source using origin as the origin
+func (s Source) loadFile(path, origin string) (*Set, error) {
+	switch s.format(path) {
+	case FormatMaster:
+		return ParseMasterFile(path, origin)
+	case FormatTOML:
+		set, err := LoadZonesFile(path)
+		if err != nil || origin == "" {
+			return set, err
+		}
+		out := make(Set, len(*set))
+		for name, z := range *set {
+			if name == "" || name == Apex {
+				out[origin] = z
+			} else {
+				out[name+"."+origin] = z
+			}
+		}
+		return &out, nil
+	default:
+		return nil, fmt.Errorf("unknown zone file format %s", s.Format)
+	}
+}
+
+// merge adds the zones in src loaded from path to dst. origins records the path every zone in dst was loaded from.
+func merge(dst, src Set, origins map[string]string, path string) error {
+	for name, z := range src {
+		key := strings.ToLower(strings.TrimSuffix(name, "."))
+		if prev, ok := origins[key]; ok {
+			return fmt.Errorf("zone %s is defined in both %s and %s", key+".", prev, path)
+		}
+		origins[key] = path
+		dst[key] = z
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"zones.toml":                "[com]\nexclusive = true\n[com.entries.example]\nA = [\"10.0.0.1\"]\n",
+		"relative.toml":             "[dev]\nexclusive = true\n[\"@\"]\nexclusive = false\n",
+		"example.org.zone":          "@ A 10.0.0.2\n",
+		"plain":                     "$ORIGIN example.net.\n@ A 10.0.0.3\n",
+		"zones/example.info.zone":   "@ A 10.0.0.4\n",
+		"zones/example.biz.db":      "www A 10.0.0.5\n",
+		"zones/.hidden":             "not a zone\n",
+		"mixed/example.toml":        "[com]\nexclusive = true\n[com.entries.example]\nA = [\"10.0.0.8\"]\n",
+		"mixed/example.net.zone":    "@ A 10.0.0.9\n",
+		"conflict/example.org":      "@ A 10.0.0.6\n",
+		"conflict/example.org.zone": "@ A 10.0.0.7\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := func(name string) string { return filepath.Join(dir, name) }
+	tests := []struct {
+		name    string
+		sources []Source
+		want    []string
+		wantErr string
+	}{
+		{"TOML", []Source{{Path: p("zones.toml")}}, []string{"com"}, ""},
+		{"TOML with origin", []Source{{Path: p("relative.toml"), Origin: "example.com."}}, []string{"dev.example.com", "example.com"}, ""},
+		{"Master file", []Source{{Path: p("example.org.zone"), Origin: "example.org"}}, []string{"example.org"}, ""},
+		{"Master file without extension", []Source{{Path: p("plain")}}, []string{"example.net"}, ""},
+		{"Explicit format", []Source{{Path: p("zones.toml"), Format: FormatMaster, Origin: "com"}}, nil, "zones.toml:1"},
+		{"Unknown format", []Source{{Path: p("zones.toml"), Format: "xml"}}, nil, "unknown zone file format"},
+		{"Directory", []Source{{Path: p("zones")}}, []string{"example.biz", "example.info"}, ""},
+		{"Directory with TOML file", []Source{{Path: p("mixed")}}, []string{"com", "example.net"}, ""},
+		{"Multiple sources", []Source{{Path: p("zones.toml")}, {Path: p("zones")}, {Path: p("example.org.zone"), Origin: "Example.ORG."}}, []string{"com", "example.biz", "example.info", "example.org"}, ""},
+		{"Conflict between sources", []Source{{Path: p("example.org.zone"), Origin: "example.org"}, {Path: p("conflict/example.org"), Origin: "example.org"}}, nil, "defined in both"},
+		{"Conflict in directory", []Source{{Path: p("conflict")}}, nil, "defined in both"},
+		{"Missing file", []Source{{Path: p("missing.toml")}}, nil, "no such file"},
+		{"Missing optional file", []Source{{Path: p("missing.toml"), Optional: true}, {Path: p("zones.toml")}}, []string{"com"}, ""},
+		{"Invalid TOML", []Source{{Path: p("example.org.zone"), Format: FormatTOML}}, nil, "example.org.zone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := Load(tt.sources...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			var got []string
+			for name := range *set {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Load() zones = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
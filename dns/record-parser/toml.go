@@ -7,17 +7,32 @@ import (
 	"github.com/naoina/toml"
 )
 
+// ZonesFile is the default path of the zones file
+const ZonesFile = "zones.toml"
+
 // ParseZonesFile parses the zone file
 func ParseZonesFile() *Set {
-	f, err := os.Open("zones.toml")
+	set, err := LoadZonesFile(ZonesFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
+		fmt.Printf("Failed to parse %s: %s.\nContinuing without local zones.\n", ZonesFile, err.Error())
 		return nil
 	}
+	return set
+}
+
+// LoadZonesFile parses the TOML zones file at path
+func LoadZonesFile(path string) (*Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close() //nolint: errcheck
 	set := new(Set)
 	if err := toml.NewDecoder(f).Decode(set); err != nil {
-		fmt.Printf("Failed to parse zones.toml: %s.\nContinuing without local zones.\n", err.Error())
-		return nil
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
 	}
-	return set
+	return set, nil
 }
@@ -1,48 +1,151 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/fossoreslp/go-dns/dns/record-names"
 	"github.com/fossoreslp/go-dns/dns/record-types"
 )
 
+// DefaultTTL is used for records without a TTL in zones that specify neither a default TTL nor a SOA record
+const DefaultTTL = 3600
+
 // Set is a set of DNS zones
 type Set map[string]Zone
 
 // Zone is a DNS zone
 type Zone struct {
 	Exclusive bool
-	Entries   map[string]Entry
+	// TTL is the default TTL of the zone as set by $TTL or the ttl key. Zero if not set.
+	TTL     uint32
+	Entries map[string]Entry
+
+	// nonTerminals holds the relative names of the empty non-terminals of the zone. It is built by Index.
+	nonTerminals map[string]bool
 }
 
 // Entry is one particular location in a DNS zone
-type Entry map[names.TYPE][]record.Record
+type Entry map[names.TYPE]RRSet
 
-// GetRecordsOfType returns all records in an entry matching the specified type
-func (e *Entry) GetRecordsOfType(t names.QTYPE) []record.Record {
+// RRSet is a set of records of the same type at the same location sharing a TTL (RFC 2181 section 5)
+type RRSet struct {
+	TTL     uint32
+	Records []record.Record
+}
+
+// GetRecordsOfType returns all records in an entry matching the specified type.
+// If records of multiple types match, the lowest TTL of their sets is used.
+func (e *Entry) GetRecordsOfType(t names.QTYPE) RRSet {
 	switch t {
 	case names.AXFR, names.QTYPE_ANY:
-		return nil
+		return RRSet{}
 	case names.MAILB:
-		return append((*e)[names.MD], (*e)[names.MF]...)
+		return e.merge(names.MD, names.MF)
 	case names.MAILA:
-		out := append((*e)[names.MB], (*e)[names.MG]...)
-		out = append(out, (*e)[names.MR]...)
-		return append(out, (*e)[names.MINFO]...)
+		return e.merge(names.MB, names.MG, names.MR, names.MINFO)
 	default:
 		return (*e)[names.TYPE(t)]
 	}
 }
 
+func (e *Entry) merge(types ...names.TYPE) RRSet {
+	var out RRSet
+	for _, t := range types {
+		rs, ok := (*e)[t]
+		if !ok || len(rs.Records) == 0 {
+			continue
+		}
+		if out.Records == nil || rs.TTL < out.TTL {
+			out.TTL = rs.TTL
+		}
+		out.Records = append(out.Records, rs.Records...)
+	}
+	return out
+}
+
+// checkCNAME returns an error if e contains a CNAME record along with other data (RFC 1034 section 3.6.2) or more than one CNAME record (RFC 2181 section 10.1)
+func checkCNAME(e Entry) error {
+	n := len(e[names.CNAME].Records)
+	if n == 0 {
+		return nil
+	}
+	if n > 1 {
+		return errors.New("only a single CNAME record is allowed per name")
+	}
+	for t, rs := range e {
+		if t != names.CNAME && len(rs.Records) > 0 {
+			return errors.New("CNAME records must not be combined with other data")
+		}
+	}
+	return nil
+}
+
+// DefaultTTL returns the TTL used for records without an explicit TTL. It is the zone's TTL if set, otherwise the minimum field of the SOA record or DefaultTTL.
+func (z *Zone) DefaultTTL() uint32 {
+	if z.TTL != 0 {
+		return z.TTL
+	}
+	if soa, _ := z.SOA(); soa != nil {
+		return soa.Minimum
+	}
+	return DefaultTTL
+}
+
 // UnmarshalTOML is a function called by the TOML parser to properly decode the zones file entries.
-func (e *Entry) UnmarshalTOML(decode func(interface{}) error) error {
-	a := new(record.Records)
-	if err := decode(a); err != nil {
-		return err
+// TTLs can be set for a zone using the ttl key, for an entry using the TTL key and for a single type in an entry using the TTLs table.
+// Entry names are case-insensitive and stored in lower case.
+func (z *Zone) UnmarshalTOML(decode func(interface{}) error) error {
+	var aux struct {
+		Exclusive bool
+		TTL       uint32
+		Entries   map[string]record.Records
 	}
-	o, err := a.Decode()
-	if err != nil {
+	if err := decode(&aux); err != nil {
 		return err
 	}
-	*e = Entry(o)
+	z.Exclusive, z.TTL, z.Entries = aux.Exclusive, aux.TTL, make(map[string]Entry, len(aux.Entries))
+	implicit := make(map[string][]names.TYPE)
+	for name, a := range aux.Entries {
+		name = strings.ToLower(name)
+		if _, ok := z.Entries[name]; ok {
+			return fmt.Errorf("entry %s is defined more than once", name)
+		}
+		o, err := a.Decode()
+		if err != nil {
+			return err
+		}
+		e := make(Entry)
+		for t, rs := range o {
+			if len(rs) == 0 {
+				continue
+			}
+			n, _ := names.IntToType(uint16(t))
+			ttl, ok := a.TTLs[n]
+			if !ok && a.TTL != nil {
+				ttl, ok = *a.TTL, true
+			}
+			if !ok {
+				implicit[name] = append(implicit[name], t)
+			}
+			e[t] = RRSet{ttl, rs}
+		}
+		for n := range a.TTLs {
+			if t, ok := names.TypeToInt(n); !ok || e[names.TYPE(t)].Records == nil {
+				return fmt.Errorf("TTL set for %s but entry %s has no records of that type", n, name)
+			}
+		}
+		if err := checkCNAME(e); err != nil {
+			return fmt.Errorf("entry %s: %s", name, err.Error())
+		}
+		z.Entries[name] = e
+	}
+	ttl := z.DefaultTTL()
+	for name, types := range implicit {
+		for _, t := range types {
+			z.Entries[name][t] = RRSet{ttl, z.Entries[name][t].Records}
+		}
+	}
 	return nil
 }
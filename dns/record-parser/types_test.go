@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/naoina/toml"
+)
+
+const testTOML = `
+[com]
+	exclusive = true
+	[com.entries]
+		[com.entries.example]
+			A = ["10.0.0.2"]
+		[com.entries.Mixed]
+			A = ["10.0.0.6"]
+		[com.entries."*.dev"]
+			A = ["10.0.0.5"]
+		[com.entries.ttl]
+			TTL = 300
+			A = ["10.0.0.3"]
+			TXT = ["text"]
+			[com.entries.ttl.TTLs]
+				TXT = 60
+
+[org]
+	ttl = 7200
+	[org.entries]
+		[org.entries.example]
+			A = ["10.0.0.4"]
+		[org.entries."@"]
+			SOA = ["ns.example.org hostmaster.example.org 1 2 3 4 5"]
+`
+
+func TestZone_UnmarshalTOML(t *testing.T) {
+	set := new(Set)
+	if err := toml.Unmarshal([]byte(testTOML), set); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		zone  string
+		entry string
+		typ   names.TYPE
+		want  uint32
+	}{
+		{"com", "example", names.A, DefaultTTL},
+		{"com", "ttl", names.A, 300},
+		{"com", "ttl", names.TXT, 60},
+		{"com", "*.dev", names.A, DefaultTTL},
+		{"com", "mixed", names.A, DefaultTTL},
+		{"org", "example", names.A, 7200},
+		{"org", Apex, names.SOA, 7200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone+"/"+tt.entry, func(t *testing.T) {
+			rs := (*set)[tt.zone].Entries[tt.entry][tt.typ]
+			if len(rs.Records) != 1 || rs.TTL != tt.want {
+				t.Errorf("UnmarshalTOML() = %v, want one record with TTL %d", rs, tt.want)
+			}
+		})
+	}
+	if _, ok := (*set)["com"].Entries["example"][names.AAAA]; ok {
+		t.Error("UnmarshalTOML() created an empty RRset")
+	}
+
+	err := toml.Unmarshal([]byte("[com.entries.example]\nA = [\"10.0.0.1\"]\n[com.entries.example.TTLs]\nMX = 60\n"), new(Set))
+	if err == nil {
+		t.Error("UnmarshalTOML() accepted a TTL for a type without records")
+	}
+	err = toml.Unmarshal([]byte("[com.entries.example]\nA = [\"10.0.0.1\"]\nCNAME = [\"example.org\"]\n"), new(Set))
+	if err == nil {
+		t.Error("UnmarshalTOML() accepted a CNAME record next to other data")
+	}
+	err = toml.Unmarshal([]byte("[com.entries.example]\nA = [\"10.0.0.1\"]\n[com.entries.Example]\nA = [\"10.0.0.2\"]\n"), new(Set))
+	if err == nil {
+		t.Error("UnmarshalTOML() accepted entries differing only in case")
+	}
+}
+
+func TestEntry_GetRecordsOfType(t *testing.T) {
+	md, mf := &record.CNAME{}, &record.PTR{}
+	e := Entry{
+		names.A:  {300, []record.Record{&record.A{}}},
+		names.MD: {600, []record.Record{md}},
+		names.MF: {60, []record.Record{mf}},
+	}
+	tests := []struct {
+		name string
+		t    names.QTYPE
+		want RRSet
+	}{
+		{"Type", names.QTYPE(names.A), e[names.A]},
+		{"Missing", names.QTYPE(names.AAAA), RRSet{}},
+		{"ANY", names.QTYPE_ANY, RRSet{}},
+		{"MAILB", names.MAILB, RRSet{60, []record.Record{md, mf}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.GetRecordsOfType(tt.t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Entry.GetRecordsOfType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,46 +1,130 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
 )
 
-// FindMatchingZone tries to find the highest (if any) matching zone for label in set
+// Apex is the key of the entry holding the records at the apex of a zone
+const Apex = "@"
+
+// FindMatchingZone tries to find the closest (if any) zone enclosing label in set and returns it along with the number of sections of label below the zone
 func FindMatchingZone(l label.Label, set *Set) (*Zone, int) {
-	var highestMatchingZone *Zone
-	var sectionCountOfMatch int
-	for s := 1; s < len(l); s++ {
+	for s := 0; s < len(l); s++ {
 		if val, ok := (*set)[concat(l[s:])]; ok {
-			highestMatchingZone = &val
-			sectionCountOfMatch = s
+			return &val, s
 		}
 	}
-	return highestMatchingZone, sectionCountOfMatch
+	return nil, 0
 }
 
 // FindMatchingEntry tries to find a matching entry for label in zone which occupies zoneSections sections of zone
 func FindMatchingEntry(l label.Label, zone *Zone, zoneSections int) *Entry {
-	if val, ok := (*zone).Entries[concat(l[:zoneSections])]; ok {
+	if val, ok := (*zone).Entries[Key(l, zoneSections)]; ok {
 		return &val
 	}
 	return nil
 }
 
-// Match tries to find a matching entry for label in set and returns that entry and if the zone is exclusive
+// Key returns the key of the entry for label in a zone where zoneSections is the number of sections of label below the zone
+func Key(l label.Label, zoneSections int) string {
+	if zoneSections == 0 {
+		return Apex
+	}
+	return concat(l[:zoneSections])
+}
+
+// Index records the empty non-terminals of every zone in set. It has to be called whenever entries are added or removed.
+func (s *Set) Index() {
+	for name, z := range *s {
+		z.Index()
+		(*s)[name] = z
+	}
+}
+
+// Index records the empty non-terminals of zone, the names without an entry of their own but with entries below them (RFC 8020).
+// It has to be called whenever entries are added or removed.
+func (z *Zone) Index() {
+	z.nonTerminals = make(map[string]bool)
+	for k := range z.Entries {
+		if k == Apex {
+			continue
+		}
+		labels := strings.Split(k, ".")
+		for i := 1; i <= len(labels); i++ {
+			parent := Apex
+			if i < len(labels) {
+				parent = strings.Join(labels[i:], ".")
+			}
+			if _, ok := z.Entries[parent]; !ok {
+				z.nonTerminals[parent] = true
+			}
+		}
+	}
+}
+
+// IsEmptyNonTerminal reports whether the relative name key has no entry in zone but entries below it. Only names recorded by Index are known.
+func (z *Zone) IsEmptyNonTerminal(key string) bool {
+	return z.nonTerminals[key]
+}
+
+// Wildcard returns the wildcard entry matching label which has no entry of its own in zone or nil if there is none.
+// zoneSections is the number of sections of label below the zone. The wildcard has to be a child of the closest encloser of label (RFC 4592 section 3.3.1).
+func (z *Zone) Wildcard(l label.Label, zoneSections int) *Entry {
+	for i := 1; i <= zoneSections; i++ {
+		encloser := Key(l[i:], zoneSections-i)
+		if _, ok := z.Entries[encloser]; !ok && !z.IsEmptyNonTerminal(encloser) {
+			continue
+		}
+		key := "*"
+		if encloser != Apex {
+			key += "." + encloser
+		}
+		if e, ok := z.Entries[key]; ok {
+			return &e
+		}
+		return nil
+	}
+	return nil
+}
+
+// Delegation returns the NS records of the topmost zone cut at or above label inside zone along with the number of sections of label below the cut.
+// zoneSections is the number of sections of label below the zone. If there is no zone cut, the returned RRSet is empty.
+func (z *Zone) Delegation(l label.Label, zoneSections int) (RRSet, int) {
+	for i := zoneSections - 1; i >= 0; i-- {
+		if rs := z.Entries[Key(l[i:], zoneSections-i)][names.NS]; len(rs.Records) > 0 {
+			return rs, i
+		}
+	}
+	return RRSet{}, 0
+}
+
+// SOA returns the SOA record at the apex of zone along with the TTL of its RRset or nil if there is none
+func (z *Zone) SOA() (*record.SOA, uint32) {
+	rs := z.Entries[Apex][names.SOA]
+	if len(rs.Records) == 0 {
+		return nil, 0
+	}
+	soa, _ := rs.Records[0].(*record.SOA)
+	return soa, rs.TTL
+}
+
+// Match tries to find a matching entry for label in set including wildcards and returns that entry and if the zone is exclusive.
+// The zones have to be indexed.
 func Match(l label.Label, set *Set) (*Entry, bool) {
 	z, c := FindMatchingZone(l, set)
 	if z == nil {
 		return nil, false
 	}
-	return FindMatchingEntry(l, z, c), z.Exclusive
+	if e := FindMatchingEntry(l, z, c); e != nil || z.IsEmptyNonTerminal(Key(l, c)) {
+		return e, z.Exclusive
+	}
+	return z.Wildcard(l, c), z.Exclusive
 }
 
-func concat(s []string) (out string) {
-	if len(s) == 1 {
-		return s[0]
-	}
-	for _, p := range s {
-		out += "." + p
-	}
-	out = out[1:]
-	return
+func concat(s []string) string {
+	return strings.ToLower(strings.Join(s, "."))
 }
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+)
+
+func TestMatch(t *testing.T) {
+	entry := func(last byte) Entry {
+		return Entry{names.A: RRSet{60, []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, last}}}}}
+	}
+	set := &Set{
+		"example.com":     Zone{Entries: map[string]Entry{Apex: entry(1), "www": entry(2), "a.sub": entry(3)}},
+		"sub.example.com": Zone{Exclusive: true, Entries: map[string]Entry{Apex: entry(4), "a": entry(5)}},
+	}
+	tests := []struct {
+		name          string
+		l             label.Label
+		want          byte
+		wantExclusive bool
+	}{
+		{"Apex", label.Label{"example", "com"}, 1, false},
+		{"Entry", label.Label{"www", "example", "com"}, 2, false},
+		{"Closest zone", label.Label{"a", "sub", "example", "com"}, 5, true},
+		{"Closest zone apex", label.Label{"sub", "example", "com"}, 4, true},
+		{"Missing entry", label.Label{"mail", "example", "com"}, 0, false},
+		{"No zone", label.Label{"example", "org"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, excl := Match(tt.l, set)
+			var got byte
+			if e != nil {
+				got = (*e)[names.A].Records[0].(*record.A).IPv4[3]
+			}
+			if got != tt.want || excl != tt.wantExclusive {
+				t.Errorf("Match() = entry %d, %v, want entry %d, %v", got, excl, tt.want, tt.wantExclusive)
+			}
+		})
+	}
+}
+
+func TestZone_IsEmptyNonTerminal(t *testing.T) {
+	z := &Zone{Entries: map[string]Entry{Apex: {}, "a.b.c": {}, "c": {}, "*.x": {}}}
+	z.Index()
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"b.c", true},
+		{"x", true},
+		{"c", false},
+		{"a.b.c", false},
+		{Apex, false},
+		{"d", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := z.IsEmptyNonTerminal(tt.key); got != tt.want {
+				t.Errorf("Zone.IsEmptyNonTerminal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
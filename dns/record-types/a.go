@@ -3,7 +3,7 @@ package record
 import (
 	"errors"
 	"fmt"
-	"strconv"
+	"net"
 	"strings"
 
 	"github.com/fossoreslp/go-dns/dns/record-names"
@@ -25,17 +25,11 @@ func (r A) String() string {
 
 // Parse reads the string representation of an IPv4 into the A record
 func (r *A) Parse(i string) error {
-	s := strings.Split(i, ".")
-	if len(s) != 4 {
+	ip := net.ParseIP(i)
+	if ip == nil || ip.To4() == nil || strings.Contains(i, ":") {
 		return errors.New("IPv4 string has to have 4 dot seperated sections")
 	}
-	for i := 0; i < 4; i++ {
-		n, err := strconv.Atoi(s[i])
-		if err != nil {
-			return err
-		}
-		r.IPv4[i] = uint8(n)
-	}
+	copy(r.IPv4[:], ip.To4())
 	return nil
 }
 
@@ -1,9 +1,8 @@
 package record
 
 import (
-	"encoding/hex"
 	"errors"
-	"fmt"
+	"net"
 	"strings"
 
 	"github.com/fossoreslp/go-dns/dns/record-names"
@@ -20,36 +19,20 @@ func (r AAAA) Type() names.TYPE {
 }
 
 func (r AAAA) String() string {
-	return fmt.Sprintf("%x:%x:%x:%x:%x:%x:%x:%x", r.IPv6[:2], r.IPv6[2:4], r.IPv6[4:6], r.IPv6[6:8], r.IPv6[8:10], r.IPv6[10:12], r.IPv6[12:14], r.IPv6[14:16])
+	ip := net.IP(r.IPv6[:])
+	if v4 := ip.To4(); v4 != nil {
+		return "::ffff:" + v4.String() // net.IP would print IPv4-mapped addresses as plain IPv4
+	}
+	return ip.String()
 }
 
 // Parse stores an IPv6 string in the AAAA
 func (r *AAAA) Parse(i string) error {
-	s := strings.Split(i, ":")
-	if len(s) != 8 {
-		return errors.New("IPv6 address has to consist of 8 colon seperated blocks")
-	}
-	for i, v := range s {
-		if len(v) != 4 {
-			return errors.New("IPv6 block has to consist of 4 hex characters")
-		}
-		o1, err := hex.DecodeString(v[:2])
-		if err != nil {
-			return err
-		}
-		if len(o1) != 1 {
-			return errors.New("HEX decode of 2 letters did not produce one byte")
-		}
-		o2, err := hex.DecodeString(v[2:4])
-		if err != nil {
-			return err
-		}
-		if len(o2) != 1 {
-			return errors.New("HEX decode of 2 letters did not produce one byte")
-		}
-		r.IPv6[i*2] = o1[0]
-		r.IPv6[i*2+1] = o2[0]
+	ip := net.ParseIP(i)
+	if ip == nil || !strings.Contains(i, ":") {
+		return errors.New("invalid IPv6 address")
 	}
+	copy(r.IPv6[:], ip.To16())
 	return nil
 }
 
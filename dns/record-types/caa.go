@@ -22,12 +22,12 @@ func (r CAA) Type() names.TYPE {
 }
 
 func (r CAA) String() string {
-	return fmt.Sprintf("%d %s %s", r.Flags, r.Tag, r.Value)
+	return fmt.Sprintf("%d %s %s", r.Flags, r.Tag, quoteCharacterString(r.Value))
 }
 
-// Parse stores input in CAA
+// Parse stores input in CAA. The value may be enclosed in quotes.
 func (r *CAA) Parse(i string) error {
-	s := strings.SplitN(i, " ", 3)
+	s := strings.SplitN(strings.TrimSpace(i), " ", 3)
 	if len(s) != 3 {
 		return errors.New("CAA records need to have the format \"Flags Tag Value\"")
 	}
@@ -35,7 +35,7 @@ func (r *CAA) Parse(i string) error {
 	if err != nil {
 		return err
 	}
-	if f != 128 {
+	if f&0x7F != 0 {
 		return errors.New("only first bit of flag may be set")
 	}
 	r.Flags = uint8(f)
@@ -43,7 +43,14 @@ func (r *CAA) Parse(i string) error {
 		return errors.New("tag length may not exceed 255 characters")
 	}
 	r.Tag = s[1]
-	r.Value = s[2]
+	r.Value = strings.TrimSpace(s[2])
+	if strings.HasPrefix(r.Value, "\"") {
+		v, err := parseCharacterStrings(r.Value)
+		if err != nil {
+			return err
+		}
+		r.Value = strings.Join(v, "")
+	}
 	return nil
 }
 
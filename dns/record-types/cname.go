@@ -36,6 +36,11 @@ func (r CNAME) Encode() []byte {
 	return r.Label.Encode()
 }
 
+// EncodeCompressed encodes the record to DNS message format using name compression
+func (r CNAME) EncodeCompressed(c label.Compression, off int) []byte {
+	return r.Label.EncodeCompressed(c, off)
+}
+
 // Decode parses the input from DNS message format
 func (r *CNAME) Decode(i []byte, start, length int) error {
 	l, end, err := label.GetLabelsFromMessage(i, start)
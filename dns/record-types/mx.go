@@ -23,14 +23,17 @@ func (r MX) Type() names.TYPE {
 }
 
 func (r MX) String() string {
-	return fmt.Sprintf("%s %d", r.Name.String(), r.Priority)
+	return fmt.Sprintf("%d %s", r.Priority, r.Name.String())
 }
 
-// Parse stores the input in the MX
+// Parse stores the input in the MX. Both the presentation format "10 mail.example.com" and the format "mail.example.com 10" are accepted.
 func (r *MX) Parse(i string) error {
-	e := strings.Split(i, " ")
+	e := strings.Fields(i)
 	if len(e) != 2 {
-		return errors.New("MX record needs to be in format mail.example.com 10")
+		return errors.New("MX record needs to be in format 10 mail.example.com")
+	}
+	if _, err := strconv.ParseUint(e[0], 10, 16); err == nil {
+		e[0], e[1] = e[1], e[0]
 	}
 	l, err := label.Parse(e[0])
 	if err != nil {
@@ -39,7 +42,7 @@ func (r *MX) Parse(i string) error {
 	r.Name = l
 	p, err := strconv.ParseUint(e[1], 10, 16)
 	if err != nil {
-		return errors.New("second section of MX record has to be uint16")
+		return errors.New("preference of MX record has to be uint16")
 	}
 	r.Priority = uint16(p)
 	return nil
@@ -52,6 +55,13 @@ func (r MX) Encode() []byte {
 	return append(out, r.Name.Encode()...)
 }
 
+// EncodeCompressed returns the record in DNS message format using name compression
+func (r MX) EncodeCompressed(c label.Compression, off int) []byte {
+	out := make([]byte, 2)
+	binary.BigEndian.PutUint16(out, r.Priority)
+	return append(out, r.Name.EncodeCompressed(c, off+2)...)
+}
+
 // Decode stores the message data in the MX
 func (r *MX) Decode(i []byte, start, length int) error {
 	if length < 2 {
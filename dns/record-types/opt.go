@@ -0,0 +1,88 @@
+package record
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fossoreslp/go-dns/dns/record-names"
+)
+
+// OptionTCPKeepalive is the code of the edns-tcp-keepalive option (RFC 7828)
+const OptionTCPKeepalive uint16 = 11
+
+// Option is a single EDNS option (RFC 6891 section 6.1.2)
+type Option struct {
+	Code uint16
+	Data []byte
+}
+
+// OPT is the EDNS(0) pseudo-record (RFC 6891). Only the options are part of the record data, the remaining fields are stored in the class and TTL of the resource record.
+type OPT struct {
+	UDPSize       uint16
+	ExtendedRCode uint8
+	Version       uint8
+	DO            bool
+	Options       []Option
+}
+
+// Type returns the record type
+func (r OPT) Type() names.TYPE {
+	return names.OPT
+}
+
+func (r OPT) String() string {
+	opts := make([]string, len(r.Options))
+	for i, o := range r.Options {
+		opts[i] = fmt.Sprintf("%d:%X", o.Code, o.Data)
+	}
+	return fmt.Sprintf("EDNS version %d, UDP size %d, extended RCode %d, DO %t, options [%s]", r.Version, r.UDPSize, r.ExtendedRCode, r.DO, strings.Join(opts, " "))
+}
+
+// Parse always fails as OPT records only exist in DNS messages
+func (r *OPT) Parse(i string) error {
+	return errors.New("OPT records cannot be parsed from text")
+}
+
+// Encode returns the options in DNS message format
+func (r OPT) Encode() []byte {
+	out := make([]byte, 0)
+	for _, o := range r.Options {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint16(b[:2], o.Code)
+		binary.BigEndian.PutUint16(b[2:], uint16(len(o.Data)))
+		out = append(out, b...)
+		out = append(out, o.Data...)
+	}
+	return out
+}
+
+// Decode extracts the options from DNS message format
+func (r *OPT) Decode(i []byte, start, length int) error {
+	r.Options = nil
+	d := i[start : start+length]
+	for len(d) > 0 {
+		if len(d) < 4 {
+			return errors.New("data too short for EDNS option")
+		}
+		code := binary.BigEndian.Uint16(d[:2])
+		l := int(binary.BigEndian.Uint16(d[2:4]))
+		if len(d) < 4+l {
+			return errors.New("EDNS option exceeds data length")
+		}
+		r.Options = append(r.Options, Option{code, append([]byte(nil), d[4:4+l]...)})
+		d = d[4+l:]
+	}
+	return nil
+}
+
+// Option returns the first option with the supplied code or nil if there is none
+func (r OPT) Option(code uint16) *Option {
+	for i := range r.Options {
+		if r.Options[i].Code == code {
+			return &r.Options[i]
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package record
 
 import (
+	"github.com/fossoreslp/go-dns/dns/label"
 	"github.com/fossoreslp/go-dns/dns/record-names"
 )
 
@@ -13,6 +14,43 @@ type Record interface {
 	Type() names.TYPE
 }
 
+// New returns an empty record of type t or nil if the type is not supported
+func New(t names.TYPE) Record { // nolint: gocyclo
+	switch t {
+	case names.A:
+		return new(A)
+	case names.AAAA:
+		return new(AAAA)
+	case names.CAA:
+		return new(CAA)
+	case names.CNAME:
+		return new(CNAME)
+	case names.MX:
+		return new(MX)
+	case names.NS:
+		return new(NS)
+	case names.OPT:
+		return new(OPT)
+	case names.PTR:
+		return new(PTR)
+	case names.SOA:
+		return new(SOA)
+	case names.SRV:
+		return new(SRV)
+	case names.TXT:
+		return new(TXT)
+	default:
+		return nil
+	}
+}
+
+// Compressible is implemented by records whose data contains domain names that may be compressed.
+// Only the types defined in RFC 1035 implement it as other types must not be compressed (RFC 3597 section 4).
+type Compressible interface {
+	// EncodeCompressed encodes the record data that will be written to a message at offset off using the compression table c
+	EncodeCompressed(c label.Compression, off int) []byte
+}
+
 // Records is a struct used to decode the zones toml file.
 type Records struct {
 	A     []string
@@ -25,6 +63,10 @@ type Records struct {
 	SOA   []string
 	SRV   []string
 	TXT   []string
+	// TTL is the TTL of all records in the entry. Defaults to the TTL of the zone if not set.
+	TTL *uint32
+	// TTLs sets the TTL of the records of a single type and takes precedence over TTL
+	TTLs map[string]uint32
 }
 
 // Decode returns the records in their proper individual formats
@@ -33,7 +33,7 @@ func (r SOA) String() string {
 
 // Parse stores the input in SOA
 func (r *SOA) Parse(i string) error {
-	s := strings.Split(i, " ")
+	s := strings.Fields(i)
 	if len(s) != 7 {
 		return errors.New("SOA record has to be in format \"Primary Hostmaster Serial Refresh Retry Expire Minimum\"")
 	}
@@ -95,27 +95,40 @@ func (r SOA) Encode() []byte {
 	return append(s, b...)
 }
 
+// EncodeCompressed returns the record in DNS message format using name compression
+func (r SOA) EncodeCompressed(c label.Compression, off int) []byte {
+	s := r.MName.EncodeCompressed(c, off)
+	s = append(s, r.RName.EncodeCompressed(c, off+len(s))...)
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint32(b[:4], r.Serial)
+	binary.BigEndian.PutUint32(b[4:8], r.Refresh)
+	binary.BigEndian.PutUint32(b[8:12], r.Retry)
+	binary.BigEndian.PutUint32(b[12:16], r.Expire)
+	binary.BigEndian.PutUint32(b[16:], r.Minimum)
+	return append(s, b...)
+}
+
 // Decode parses the input from DNS message format
 func (r *SOA) Decode(i []byte, start, lenght int) error {
 	mname, m, err := label.GetLabelsFromMessage(i, start)
 	if err != nil {
 		return err
 	}
-	if m > lenght {
+	if m-start > lenght {
 		return errors.New("label exceeds data lenght")
 	}
 	r.MName = mname
 
-	rname, n, err := label.GetLabelsFromMessage(i, start+m)
+	rname, n, err := label.GetLabelsFromMessage(i, m)
 	if err != nil {
 		return err
 	}
-	if n > lenght {
+	if n-start > lenght {
 		return errors.New("label exceeds data lenght")
 	}
 	r.RName = rname
 
-	nums := i[start+m+n : start+lenght]
+	nums := i[n : start+lenght]
 	if len(nums) != 20 {
 		return errors.New("data lenght does not fit record")
 	}
@@ -30,7 +30,7 @@ func (r SRV) String() string {
 
 // Parse stores the input in SRV
 func (r *SRV) Parse(i string) error {
-	s := strings.Split(i, " ")
+	s := strings.Fields(i)
 	if len(s) != 4 {
 		return errors.New("SRV record has to be in format \"Priority Weight Port Host\"")
 	}
@@ -1,10 +1,17 @@
 package record
 
-import "github.com/fossoreslp/go-dns/dns/record-names"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fossoreslp/go-dns/dns/record-names"
+)
 
 // TXT is used to store TXT DNS records
 type TXT struct {
-	Content string
+	Strings []string
 }
 
 // Type returns the record type
@@ -13,24 +20,127 @@ func (r TXT) Type() names.TYPE {
 }
 
 func (r TXT) String() string {
-	return r.Content
+	if len(r.Strings) == 0 {
+		return "\"\""
+	}
+	s := make([]string, len(r.Strings))
+	for i, v := range r.Strings {
+		s[i] = quoteCharacterString(v)
+	}
+	return strings.Join(s, " ")
 }
 
-// Parse stores the input in TXT
+// Parse stores the input in TXT. Input starting with a quote is read as a list of quoted strings, any other input is stored as a single string.
+// Strings exceeding 255 bytes are split up.
 func (r *TXT) Parse(i string) error {
-	r.Content = i
+	var s []string
+	if strings.HasPrefix(strings.TrimSpace(i), "\"") {
+		var err error
+		if s, err = parseCharacterStrings(i); err != nil {
+			return err
+		}
+	} else {
+		s = []string{i}
+	}
+	r.Strings = nil
+	for _, v := range s {
+		for len(v) > 255 {
+			r.Strings = append(r.Strings, v[:255])
+			v = v[255:]
+		}
+		r.Strings = append(r.Strings, v)
+	}
 	return nil
 }
 
 // Encode returns the TXT in DNS message format
 func (r TXT) Encode() []byte {
-	return []byte(r.Content)
+	if len(r.Strings) == 0 {
+		return []byte{0}
+	}
+	var out []byte
+	for _, s := range r.Strings {
+		out = append(out, uint8(len(s)))
+		out = append(out, s...)
+	}
+	return out
 }
 
 // Decode extracts the TXT DNS record from DNS message format
 func (r *TXT) Decode(i []byte, start, length int) error {
-	if length > 0 {
-		r.Content = string(i[start : start+length])
+	r.Strings = nil
+	d := i[start : start+length]
+	for len(d) > 0 {
+		l := int(d[0])
+		if l >= len(d) {
+			return errors.New("character string exceeds data length")
+		}
+		r.Strings = append(r.Strings, string(d[1:1+l]))
+		d = d[1+l:]
 	}
 	return nil
 }
+
+// parseCharacterStrings reads a whitespace separated list of quoted strings (RFC 1035 section 5.1)
+func parseCharacterStrings(i string) ([]string, error) {
+	var out []string
+	i = strings.TrimSpace(i)
+	for len(i) > 0 {
+		if i[0] != '"' {
+			return nil, errors.New("character strings have to be enclosed in quotes")
+		}
+		var b []byte
+		n := 1
+		for ; n < len(i) && i[n] != '"'; n++ {
+			if i[n] != '\\' {
+				b = append(b, i[n])
+				continue
+			}
+			if n+3 < len(i) && isDigits(i[n+1:n+4]) {
+				v, err := strconv.ParseUint(i[n+1:n+4], 10, 8)
+				if err != nil {
+					return nil, fmt.Errorf("invalid escape sequence \\%s", i[n+1:n+4])
+				}
+				b = append(b, byte(v))
+				n += 3
+				continue
+			}
+			if n+1 >= len(i) {
+				return nil, errors.New("unterminated escape sequence")
+			}
+			n++
+			b = append(b, i[n])
+		}
+		if n >= len(i) {
+			return nil, errors.New("unterminated character string")
+		}
+		out = append(out, string(b))
+		i = strings.TrimSpace(i[n+1:])
+	}
+	return out, nil
+}
+
+// quoteCharacterString returns s enclosed in quotes with quotes, backslashes and non-printable characters escaped
+func quoteCharacterString(s string) string {
+	b := []byte{'"'}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20 || c > 0x7E:
+			b = append(b, fmt.Sprintf("\\%03d", c)...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(append(b, '"'))
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
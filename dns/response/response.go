@@ -73,37 +73,20 @@ func parseSection(message []byte, start int, elements uint16) (out []Response, e
 				return nil, 0, err
 			}
 		}
+		if o, ok := rt.(*record.OPT); ok {
+			o.UDPSize = c
+			o.ExtendedRCode = uint8(s >> 24)
+			o.Version = uint8(s >> 16)
+			o.DO = s&0x8000 != 0
+		}
 		out = append(out, Response{l, names.TYPE(t), names.CLASS(c), s, r, message[e+10 : e+10+int(r)], rt})
 		end = e + 10 + int(r)
 	}
 	return
 }
 
-func getRecordType(t names.TYPE) record.Record { // nolint: gocyclo
-	switch t {
-	case names.A:
-		return new(record.A)
-	case names.AAAA:
-		return new(record.AAAA)
-	case names.CAA:
-		return new(record.CAA)
-	case names.CNAME:
-		return new(record.CNAME)
-	case names.MX:
-		return new(record.MX)
-	case names.NS:
-		return new(record.NS)
-	case names.PTR:
-		return new(record.PTR)
-	case names.SOA:
-		return new(record.SOA)
-	case names.SRV:
-		return new(record.SRV)
-	case names.TXT:
-		return new(record.TXT)
-	default:
-		return nil
-	}
+func getRecordType(t names.TYPE) record.Record {
+	return record.New(t)
 }
 
 // New returns a new answer for the supplied contents
@@ -114,15 +97,43 @@ func New(name label.Label, t names.TYPE, ttl uint32, data []byte) Response {
 	return Response{name, t, names.IN, ttl, uint16(len(data)), data, nil}
 }
 
+// NewOPT returns the resource record carrying the EDNS information in o
+func NewOPT(o *record.OPT) Response {
+	ttl := uint32(o.ExtendedRCode)<<24 | uint32(o.Version)<<16
+	if o.DO {
+		ttl |= 0x8000
+	}
+	d := o.Encode()
+	return Response{label.Label{}, names.OPT, names.CLASS(o.UDPSize), ttl, uint16(len(d)), d, o}
+}
+
+// FromRecord returns a new answer for name containing the record rec
+func FromRecord(name label.Label, ttl uint32, rec record.Record) Response {
+	r := New(name, rec.Type(), ttl, rec.Encode())
+	r.Record = rec
+	return r
+}
+
 // Encode returns the Response in DNS message format
 func (r Response) Encode() []byte {
-	r.DataLength = uint16(len(r.Data))
-	b := r.Name.Encode()
-	a := make([]byte, 10+int(r.DataLength))
+	return r.EncodeCompressed(nil, 0)
+}
+
+// EncodeCompressed returns the Response in DNS message format for offset off of a message using the compression table c.
+// Names in the record data are only compressed if the decoded record is available.
+// If c is nil, no compression is used.
+func (r Response) EncodeCompressed(c label.Compression, off int) []byte {
+	b := r.Name.EncodeCompressed(c, off)
+	data := r.Data
+	if cr, ok := r.Record.(record.Compressible); ok && c != nil {
+		data = cr.EncodeCompressed(c, off+len(b)+10)
+	} else if r.Record != nil {
+		data = r.Record.Encode()
+	}
+	a := make([]byte, 10, 10+len(data))
 	binary.BigEndian.PutUint16(a[:2], uint16(r.Type))
 	binary.BigEndian.PutUint16(a[2:4], uint16(r.Class))
 	binary.BigEndian.PutUint32(a[4:8], r.TTL)
-	binary.BigEndian.PutUint16(a[8:10], r.DataLength)
-	copy(a[10:], r.Data)
-	return append(b, a...)
+	binary.BigEndian.PutUint16(a[8:10], uint16(len(data)))
+	return append(b, append(a, data...)...)
 }
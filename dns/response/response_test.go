@@ -91,6 +91,8 @@ func Test_parseSection(t *testing.T) {
 		{"Message too short for RR info", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00}, 0, 1}, nil, 0, true},
 		{"Data exceeds message length", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x0E, 0x10, 0x00, 0x04}, 0, 1}, nil, 0, true},
 		{"Data parsing error", args{[]byte{0x07, 0x65, 0x78, 0x61, 0x6D, 0x70, 0x6C, 0x65, 0x03, 0x63, 0x6F, 0x6D, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x0E, 0x10, 0x00, 0x02, 0x00, 0x00}, 0, 1}, nil, 0, true},
+		{"OPT", args{[]byte{0x00, 0x00, 0x29, 0x10, 0x00, 0x01, 0x02, 0x80, 0x00, 0x00, 0x06, 0x00, 0x0B, 0x00, 0x02, 0x00, 0x64}, 0, 1}, []Response{Response{label.Label{}, names.OPT, names.CLASS(4096), 0x01028000, 6, []byte{0x00, 0x0B, 0x00, 0x02, 0x00, 0x64}, &record.OPT{UDPSize: 4096, ExtendedRCode: 1, Version: 2, DO: true, Options: []record.Option{record.Option{Code: 11, Data: []byte{0x00, 0x64}}}}}}, 17, false},
+		{"OPT option exceeds data", args{[]byte{0x00, 0x00, 0x29, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0x0B, 0x00, 0x02}, 0, 1}, nil, 0, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -122,6 +124,7 @@ func Test_getRecordType(t *testing.T) {
 		{"CNAME", names.CNAME, new(record.CNAME)},
 		{"MX", names.MX, new(record.MX)},
 		{"NS", names.NS, new(record.NS)},
+		{"OPT", names.OPT, new(record.OPT)},
 		{"PTR", names.PTR, new(record.PTR)},
 		{"SOA", names.SOA, new(record.SOA)},
 		{"SRV", names.SRV, new(record.SRV)},
@@ -159,6 +162,24 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewOPT(t *testing.T) {
+	tests := []struct {
+		name string
+		o    *record.OPT
+		want Response
+	}{
+		{"Plain", &record.OPT{UDPSize: 1232}, Response{label.Label{}, names.OPT, names.CLASS(1232), 0, 0, []byte{}, &record.OPT{UDPSize: 1232}}},
+		{"Flags and options", &record.OPT{UDPSize: 512, ExtendedRCode: 1, DO: true, Options: []record.Option{record.Option{Code: 11, Data: []byte{0x00, 0x64}}}}, Response{label.Label{}, names.OPT, names.CLASS(512), 0x01008000, 6, []byte{0x00, 0x0B, 0x00, 0x02, 0x00, 0x64}, &record.OPT{UDPSize: 512, ExtendedRCode: 1, DO: true, Options: []record.Option{record.Option{Code: 11, Data: []byte{0x00, 0x64}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewOPT(tt.o); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewOPT() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestResponse_Encode(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/message"
+)
+
+// ACL is a handler refusing requests from clients that are not allowed to use the server
+type ACL struct {
+	// Allow lists the networks allowed to send requests. If it is empty, all clients not listed in Deny are allowed.
+	Allow []*net.IPNet
+	// Deny lists the networks whose requests are refused. It takes precedence over Allow.
+	Deny    []*net.IPNet
+	Handler Handler
+}
+
+// ServeDNS passes the request on to the handler if the client is allowed and answers it with REFUSED otherwise
+func (a *ACL) ServeDNS(w ResponseWriter, req *message.Message) {
+	if !a.Allowed(remoteIP(w.RemoteAddr())) {
+		w.WriteMsg(dnserror.New(dnserror.Refused, false).Message(req.Header.ID, req.Questions...)) //nolint: errcheck
+		return
+	}
+	a.Handler.ServeDNS(w, req)
+}
+
+// Allowed reports whether ip may send requests
+func (a *ACL) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range a.Deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(a.Allow) == 0 {
+		return true
+	}
+	for _, n := range a.Allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the IP address of a client or nil if it is unknown
+func remoteIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+	if addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
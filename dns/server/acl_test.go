@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+)
+
+func network(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func TestACL_Allowed(t *testing.T) {
+	tests := []struct {
+		name string
+		acl  ACL
+		ip   string
+		want bool
+	}{
+		{"Empty", ACL{}, "192.0.2.1", true},
+		{"Allowed", ACL{Allow: []*net.IPNet{network("192.0.2.0/24")}}, "192.0.2.1", true},
+		{"Not allowed", ACL{Allow: []*net.IPNet{network("192.0.2.0/24")}}, "198.51.100.1", false},
+		{"Denied", ACL{Deny: []*net.IPNet{network("192.0.2.0/24")}}, "192.0.2.1", false},
+		{"Deny takes precedence", ACL{Allow: []*net.IPNet{network("192.0.2.0/24")}, Deny: []*net.IPNet{network("192.0.2.128/25")}}, "192.0.2.200", false},
+		{"IPv6", ACL{Allow: []*net.IPNet{network("fd00::/8")}}, "fd00::1", true},
+		{"Unknown client", ACL{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.acl.Allowed(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("ACL.Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACL_ServeDNS(t *testing.T) {
+	q := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	tests := []struct {
+		name      string
+		acl       *ACL
+		wantRCode uint8
+	}{
+		{"Allowed", &ACL{Allow: []*net.IPNet{network("127.0.0.0/8")}, Handler: NewChain(answer(q.Name, false))}, dnserror.NoError},
+		{"Refused", &ACL{Deny: []*net.IPNet{network("127.0.0.0/8")}, Handler: NewChain(answer(q.Name, false))}, dnserror.Refused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := new(recorder)
+			tt.acl.ServeDNS(w, newRequest(q))
+			if got := w.msg.Header.ResponseCode(); got != tt.wantRCode {
+				t.Errorf("ACL.ServeDNS() RCode = %d, want %d", got, tt.wantRCode)
+			}
+		})
+	}
+}
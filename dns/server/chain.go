@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// Result is the answer a lookup step found for a single question
+type Result struct {
+	Answers []response.Response
+	// Authority holds the records for the authority section like the SOA record of a negative answer
+	Authority []response.Response
+	// Additional holds records related to the answer like the addresses of mail exchangers
+	Additional    []response.Response
+	Authoritative bool
+	// RCode is the response code of the result. Lookup steps use NameError for names that do not exist.
+	RCode uint8
+}
+
+// Lookup is a single step of a resolution pipeline
+type Lookup interface {
+	// Lookup returns the result for q or nil if the question should be passed on to the next step. Steps waiting for other servers give up once ctx is done.
+	Lookup(ctx context.Context, q query.Query) (*Result, dnserror.Error)
+}
+
+// LookupFunc is an adapter to allow the use of ordinary functions as lookup steps
+type LookupFunc func(context.Context, query.Query) (*Result, dnserror.Error)
+
+// Lookup calls f(ctx, q)
+func (f LookupFunc) Lookup(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+	return f(ctx, q)
+}
+
+// Chain is a handler that answers every question of a request by asking its lookup steps in order until one of them returns a result
+type Chain []Lookup
+
+// NewChain returns a handler that asks the supplied lookup steps in order
+func NewChain(steps ...Lookup) Chain {
+	return Chain(steps)
+}
+
+// MaxCNAMEChain limits the number of CNAME records followed while resolving a single question
+const MaxCNAMEChain = 8
+
+// Resolve asks the lookup steps in order and returns the first result. A question none of the steps can answer is refused.
+// If the answer ends in a CNAME record, its target is resolved by the chain as well and the answers are combined.
+// The authoritative flag only applies to the original name while the response code and authority section are taken from the end of the chain.
+// If resolving a target fails, the answers found so far are returned with the response code of the failure.
+func (c Chain) Resolve(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+	res, dnserr := c.lookup(ctx, q)
+	if dnserr.IsError() {
+		return nil, dnserr
+	}
+	visited := map[string]bool{strings.ToLower(q.Name.String()): true}
+	for i := 0; i < MaxCNAMEChain; i++ {
+		target := cnameTarget(q, res.Answers)
+		if target == nil {
+			return res, dnserror.Success()
+		}
+		key := strings.ToLower(target.String())
+		if visited[key] {
+			return res, dnserror.Success() // CNAME loop
+		}
+		visited[key] = true
+		next, dnserr := c.lookup(ctx, query.New(target, q.Type))
+		if dnserr.IsError() {
+			// The chain is returned as far as it could be resolved together with the error of its target
+			res.RCode = dnserr.RCode
+			return res, dnserror.Success()
+		}
+		res = &Result{
+			Answers:       append(res.Answers, next.Answers...),
+			Authority:     next.Authority,
+			Additional:    append(res.Additional, next.Additional...),
+			Authoritative: res.Authoritative,
+			RCode:         next.RCode,
+		}
+	}
+	return res, dnserror.Success()
+}
+
+// cnameTarget follows the CNAME records in answers starting at the name of q. It returns the name at the end of the chain
+// if answers do not contain records of the requested type for it or nil if there is nothing left to resolve.
+func cnameTarget(q query.Query, answers []response.Response) label.Label {
+	if q.Type == names.QTYPE(names.CNAME) || q.Type == names.QTYPE_ANY {
+		return nil
+	}
+	name := q.Name
+	for i := 0; i <= len(answers); i++ {
+		var next label.Label
+		for _, r := range answers {
+			if cname, ok := r.Record.(*record.CNAME); ok && r.Type == names.CNAME && equalNames(r.Name, name) {
+				next = cname.Label
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		name = next
+	}
+	if equalNames(name, q.Name) {
+		return nil
+	}
+	for _, r := range answers {
+		if r.Type == names.TYPE(q.Type) && equalNames(r.Name, name) {
+			return nil
+		}
+	}
+	return name
+}
+
+func equalNames(a, b label.Label) bool {
+	return strings.EqualFold(a.String(), b.String())
+}
+
+// lookup asks the lookup steps in order and returns the first result
+func (c Chain) lookup(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+	for _, step := range c {
+		res, dnserr := step.Lookup(ctx, q)
+		if dnserr.IsError() {
+			return nil, dnserr
+		}
+		if res != nil {
+			return res, dnserror.Success()
+		}
+	}
+	return nil, dnserror.New(dnserror.Refused, false)
+}
+
+// ServeDNS answers all questions of the request and writes a single response containing all answers. Lookup steps give up once the context of the request is done.
+func (c Chain) ServeDNS(w ResponseWriter, req *message.Message) {
+	responses := make([]response.Response, 0)
+	var authority, additional []response.Response
+	authoritative := false
+	rcode := dnserror.NoError
+	ctx := w.Context()
+	for _, q := range req.Questions {
+		if q.Class != names.QCLASS(names.IN) {
+			w.WriteMsg(dnserror.New(dnserror.NotImplemented, false).Message(req.Header.ID, q)) //nolint: errcheck
+			return
+		}
+		res, dnserr := c.Resolve(ctx, q)
+		if dnserr.IsError() {
+			w.WriteMsg(dnserr.Message(req.Header.ID, q)) //nolint: errcheck
+			return
+		}
+		responses = append(responses, res.Answers...)
+		authority = append(authority, res.Authority...)
+		additional = append(additional, res.Additional...)
+		authoritative = authoritative || res.Authoritative
+		if rcode == dnserror.NoError {
+			rcode = res.RCode
+		}
+	}
+	h := header.NewAnswerHeader(req.Header.ID, authoritative, req.Header.RecursionDesired())
+	h.SetResponseCode(rcode)
+	w.WriteMsg(message.New(h, req.Questions, responses, authority, additional)) //nolint: errcheck
+}
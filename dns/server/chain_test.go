@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-parser"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+type recorder struct {
+	msg *message.Message
+}
+
+func (r *recorder) WriteMsg(msg *message.Message) error {
+	r.msg = msg
+	return nil
+}
+
+func (r *recorder) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5353}
+}
+
+func (r *recorder) Context() context.Context {
+	return context.Background()
+}
+
+func newRequest(qs ...query.Query) *message.Message {
+	return message.New(header.NewQueryHeader(true), qs, nil, nil, nil)
+}
+
+func answer(name label.Label, aa bool) Lookup {
+	return LookupFunc(func(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+		return &Result{Answers: []response.Response{response.New(name, names.A, 60, []byte{10, 0, 0, 1})}, Authoritative: aa}, dnserror.Success()
+	})
+}
+
+func skip() Lookup {
+	return LookupFunc(func(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+		return nil, dnserror.Success()
+	})
+}
+
+func fail(rcode uint8) Lookup {
+	return LookupFunc(func(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+		return nil, dnserror.New(rcode, false)
+	})
+}
+
+func TestChain_Resolve(t *testing.T) {
+	q := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	tests := []struct {
+		name    string
+		c       Chain
+		want    int
+		wantErr uint8
+	}{
+		{"First step answers", NewChain(answer(q.Name, true), fail(dnserror.ServerFailure)), 1, dnserror.NoError},
+		{"Skipped step", NewChain(skip(), answer(q.Name, false)), 1, dnserror.NoError},
+		{"Error stops chain", NewChain(fail(dnserror.ServerFailure), answer(q.Name, false)), 0, dnserror.ServerFailure},
+		{"No step answers", NewChain(skip(), skip()), 0, dnserror.Refused},
+		{"Empty chain", NewChain(), 0, dnserror.Refused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.c.Resolve(context.Background(), q)
+			if err.RCode != tt.wantErr {
+				t.Errorf("Chain.Resolve() error = %v, wantErr %v", err.RCode, tt.wantErr)
+				return
+			}
+			if got == nil && tt.want != 0 || got != nil && len(got.Answers) != tt.want {
+				t.Errorf("Chain.Resolve() = %v, want %d answers", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChain_ServeDNS(t *testing.T) {
+	a := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	chaos := query.Query{Name: label.Label{"example", "com"}, Type: names.QTYPE(names.A), Class: names.QCLASS(names.CH)}
+	tests := []struct {
+		name      string
+		c         Chain
+		req       *message.Message
+		wantRCode uint8
+		wantAA    bool
+		wantCount int
+	}{
+		{"Single question", NewChain(answer(a.Name, false)), newRequest(a), dnserror.NoError, false, 1},
+		{"Authoritative", NewChain(answer(a.Name, true)), newRequest(a), dnserror.NoError, true, 1},
+		{"Multiple questions", NewChain(answer(a.Name, false)), newRequest(a, a), dnserror.NoError, false, 2},
+		{"Unsupported class", NewChain(answer(a.Name, false)), newRequest(chaos), dnserror.NotImplemented, false, 0},
+		{"Failing step", NewChain(fail(dnserror.NameError)), newRequest(a), dnserror.NameError, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := new(recorder)
+			tt.c.ServeDNS(w, tt.req)
+			if w.msg == nil {
+				t.Fatal("Chain.ServeDNS() did not write a response")
+			}
+			if !reflect.DeepEqual(w.msg.Header.ID, tt.req.Header.ID) {
+				t.Errorf("Chain.ServeDNS() ID = %X, want %X", w.msg.Header.ID, tt.req.Header.ID)
+			}
+			if got := w.msg.Header.ResponseCode(); got != tt.wantRCode {
+				t.Errorf("Chain.ServeDNS() RCode = %v, want %v", got, tt.wantRCode)
+			}
+			if got := w.msg.Header.AuthoritativeAnswer(); got != tt.wantAA {
+				t.Errorf("Chain.ServeDNS() AA = %v, want %v", got, tt.wantAA)
+			}
+			if got := len(w.msg.Answers); got != tt.wantCount {
+				t.Errorf("Chain.ServeDNS() answers = %v, want %v", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestChain_Resolve_CNAME(t *testing.T) {
+	cname := func(target string) parser.Entry {
+		return parser.Entry{names.CNAME: {TTL: 60, Records: []record.Record{&record.CNAME{Label: label.Label(strings.Split(target, "."))}}}}
+	}
+	a := parser.Entry{names.A: {TTL: 60, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, 1}}}}}
+	entries := map[string]parser.Entry{
+		"www":   cname("mail.example.com"),
+		"mail":  a,
+		"ext":   cname("host.example.net"),
+		"full":  cname("full.example.net"),
+		"loop1": cname("loop2.example.com"),
+		"loop2": cname("loop1.example.com"),
+		"dead":  cname("nope.example.com"),
+		"fail":  cname("fail.example.net"),
+		"other": cname("other.example.org"),
+	}
+	for i := 0; i < 2*MaxCNAMEChain; i++ {
+		entries[fmt.Sprintf("c%d", i)] = cname(fmt.Sprintf("c%d.example.com", i+1))
+	}
+	zones := NewZones(&parser.Set{"example.com": parser.Zone{Exclusive: true, Entries: entries}})
+	upstreamCalls := 0
+	upstream := LookupFunc(func(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+		upstreamCalls++
+		switch q.Name.String() {
+		case "host.example.net.":
+			return &Result{Answers: []response.Response{response.FromRecord(q.Name, 60, &record.A{IPv4: [4]byte{10, 0, 1, 1}})}}, dnserror.Success()
+		case "full.example.net.":
+			target := label.Label{"target", "example", "net"}
+			return &Result{Answers: []response.Response{
+				response.FromRecord(q.Name, 60, &record.CNAME{Label: target}),
+				response.FromRecord(target, 60, &record.A{IPv4: [4]byte{10, 0, 1, 2}}),
+			}}, dnserror.Success()
+		case "fail.example.net.":
+			return nil, dnserror.New(dnserror.ServerFailure, false)
+		}
+		return nil, dnserror.Success()
+	})
+	c := NewChain(zones, upstream)
+	tests := []struct {
+		name          string
+		q             query.Query
+		wantAnswers   int
+		wantRCode     uint8
+		wantUpstream  int
+		wantLastOwner string
+	}{
+		{"Local target", query.New(label.Label{"www", "example", "com"}, names.QTYPE(names.A)), 2, dnserror.NoError, 0, "mail.example.com."},
+		{"CNAME question", query.New(label.Label{"www", "example", "com"}, names.QTYPE(names.CNAME)), 1, dnserror.NoError, 0, "www.example.com."},
+		{"Upstream target", query.New(label.Label{"ext", "example", "com"}, names.QTYPE(names.A)), 2, dnserror.NoError, 1, "host.example.net."},
+		{"Upstream chain", query.New(label.Label{"full", "example", "com"}, names.QTYPE(names.A)), 3, dnserror.NoError, 1, "target.example.net."},
+		{"Loop", query.New(label.Label{"loop1", "example", "com"}, names.QTYPE(names.A)), 2, dnserror.NoError, 0, "loop2.example.com."},
+		{"Missing target", query.New(label.Label{"dead", "example", "com"}, names.QTYPE(names.A)), 1, dnserror.NameError, 0, "dead.example.com."},
+		{"Failing target", query.New(label.Label{"fail", "example", "com"}, names.QTYPE(names.A)), 1, dnserror.ServerFailure, 1, "fail.example.com."},
+		{"Unanswered target", query.New(label.Label{"other", "example", "com"}, names.QTYPE(names.A)), 1, dnserror.Refused, 1, "other.example.com."},
+		{"Depth limit", query.New(label.Label{"c0", "example", "com"}, names.QTYPE(names.A)), MaxCNAMEChain + 1, dnserror.NoError, 0, fmt.Sprintf("c%d.example.com.", MaxCNAMEChain)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamCalls = 0
+			got, err := c.Resolve(context.Background(), tt.q)
+			if err.IsError() {
+				t.Fatalf("Chain.Resolve() error = %v", err)
+			}
+			if len(got.Answers) != tt.wantAnswers || got.RCode != tt.wantRCode || !got.Authoritative {
+				t.Fatalf("Chain.Resolve() = %d answers, RCode %d, AA %v, want %d answers, RCode %d", len(got.Answers), got.RCode, got.Authoritative, tt.wantAnswers, tt.wantRCode)
+			}
+			if upstreamCalls != tt.wantUpstream {
+				t.Errorf("Chain.Resolve() asked upstream %d times, want %d", upstreamCalls, tt.wantUpstream)
+			}
+			if last := got.Answers[len(got.Answers)-1].Name.String(); last != tt.wantLastOwner {
+				t.Errorf("Chain.Resolve() last answer for %s, want %s", last, tt.wantLastOwner)
+			}
+		})
+	}
+}
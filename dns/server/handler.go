@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/fossoreslp/go-dns/dns/message"
+)
+
+// Handler responds to a DNS request
+type Handler interface {
+	ServeDNS(w ResponseWriter, req *message.Message)
+}
+
+// HandlerFunc is an adapter to allow the use of ordinary functions as handlers
+type HandlerFunc func(ResponseWriter, *message.Message)
+
+// ServeDNS calls f(w, req)
+func (f HandlerFunc) ServeDNS(w ResponseWriter, req *message.Message) {
+	f(w, req)
+}
+
+// ResponseWriter is used by a handler to send the response to a request
+type ResponseWriter interface {
+	// WriteMsg encodes and sends the response message
+	WriteMsg(msg *message.Message) error
+	// RemoteAddr returns the address of the client that sent the request
+	RemoteAddr() net.Addr
+	// Context returns the context of the request. It is done once the server gave up on answering the request.
+	Context() context.Context
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// DefaultHTTPSAddr is the address DNS over HTTPS is served on if Server.Addr is not set
+const DefaultHTTPSAddr = ":443"
+
+// DefaultHTTPSPath is the path DNS over HTTPS is served on by ListenAndServeHTTPS if Server.HTTPSPath is not set (RFC 8484 section 4.1.1)
+const DefaultHTTPSPath = "/dns-query"
+
+// MediaType is the media type of DNS messages sent via HTTPS (RFC 8484 section 6)
+const MediaType = "application/dns-message"
+
+// JSONMediaType is the media type of the JSON format offered by public resolvers like Google and Cloudflare
+const JSONMediaType = "application/dns-json"
+
+// maxHTTPMessage is the maximum size of a DNS message
+const maxHTTPMessage = 65535
+
+// ListenAndServeHTTPS listens on the TCP address s.Addr and serves DNS over HTTPS on s.HTTPSPath using config
+func (s *Server) ListenAndServeHTTPS(config *tls.Config) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultHTTPSAddr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeHTTPS(l, config)
+}
+
+// ServeHTTPS accepts TLS connections on l and serves DNS over HTTPS on s.HTTPSPath. It always returns a non-nil error and closes l.
+func (s *Server) ServeHTTPS(l net.Listener, config *tls.Config) error {
+	if config == nil {
+		l.Close() //nolint: errcheck
+		return ErrNoTLSConfig
+	}
+	path := s.HTTPSPath
+	if path == "" {
+		path = DefaultHTTPSPath
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, s)
+	config = config.Clone()
+	config.NextProtos = []string{"h2", "http/1.1"}
+	hs := &http.Server{
+		Handler:      mux,
+		TLSConfig:    config,
+		ReadTimeout:  s.ReadTimeout,
+		WriteTimeout: s.WriteTimeout,
+		IdleTimeout:  s.idleTimeout(),
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close() //nolint: errcheck
+		return ErrServerClosed
+	}
+	s.http = append(s.http, hs)
+	s.mu.Unlock()
+	err := hs.ServeTLS(l, "", "")
+	if s.isClosed() {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// ServeHTTP answers DNS over HTTPS requests (RFC 8484) sent via GET with the dns parameter or via POST.
+// GET requests with a name parameter instead are answered in the JSON format as application/dns-json.
+// The server can be mounted on any path of an existing HTTP server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var err error
+	jsonFormat := false
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		params := r.URL.Query()
+		switch {
+		case params.Get("dns") != "":
+			data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(params.Get("dns"), "="))
+		case params.Get("name") != "":
+			jsonFormat = true
+			data, err = jsonQuery(params.Get("name"), params.Get("type"), params.Get("do"), params.Get("cd"))
+		default:
+			err = errors.New("missing dns or name parameter")
+		}
+	case http.MethodPost:
+		// Parameters and the case of the media type do not matter (RFC 7231 section 3.1.1.1)
+		ct := r.Header.Get("Content-Type")
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != MediaType {
+			http.Error(w, fmt.Sprintf("unsupported content type %s", ct), http.StatusUnsupportedMediaType)
+			return
+		}
+		data, err = ioutil.ReadAll(io.LimitReader(r.Body, maxHTTPMessage+1))
+		if err == nil && len(data) > maxHTTPMessage {
+			http.Error(w, "message too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hw := &httpWriter{ctx: r.Context(), remote: httpRemoteAddr(r.RemoteAddr)}
+	s.serve(hw, data)
+	if hw.msg == nil {
+		http.Error(w, "invalid DNS message", http.StatusBadRequest)
+		return
+	}
+	var out []byte
+	if jsonFormat {
+		w.Header().Set("Content-Type", JSONMediaType)
+		out, err = json.Marshal(newJSONMessage(hw.msg))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		w.Header().Set("Content-Type", MediaType)
+		out = hw.msg.Encode()
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", minTTL(hw.msg)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.Write(out) //nolint: errcheck
+}
+
+// minTTL returns the lowest TTL of the answer section or the authority section of negative answers.
+// HTTP caches must not keep a response longer than the records it contains (RFC 8484 section 5.1). Responses without records are not cached.
+func minTTL(msg *message.Message) uint32 {
+	rs := msg.Answers
+	if len(rs) == 0 {
+		rs = msg.Authorities
+	}
+	var ttl uint32
+	found := false
+	for _, r := range rs {
+		if r.Type == names.OPT {
+			continue
+		}
+		if !found || r.TTL < ttl {
+			ttl, found = r.TTL, true
+		}
+	}
+	return ttl
+}
+
+// httpRemoteAddr returns the client address of an HTTP request
+func httpRemoteAddr(addr string) net.Addr {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+	p, _ := strconv.Atoi(port) //nolint: errcheck
+	return &net.TCPAddr{IP: net.ParseIP(host), Port: p}
+}
+
+// httpWriter stores the response to a request received via HTTPS
+type httpWriter struct {
+	ctx    context.Context
+	remote net.Addr
+	msg    *message.Message
+}
+
+func (w *httpWriter) RemoteAddr() net.Addr {
+	return w.remote
+}
+
+func (w *httpWriter) Context() context.Context {
+	return w.ctx
+}
+
+func (w *httpWriter) WriteMsg(msg *message.Message) error {
+	if msg == nil {
+		return errors.New("cannot send empty message")
+	}
+	w.msg = msg
+	return nil
+}
+
+// jsonQuery returns the encoded query for the parameters of a JSON request. The type may be given by name or number and defaults to A.
+// Setting do requests DNSSEC records via EDNS and cd disables their validation.
+func jsonQuery(name, typ, do, cd string) ([]byte, error) {
+	l, err := label.Parse(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %s: %s", name, err.Error())
+	}
+	t := uint16(names.A)
+	if typ != "" {
+		var ok bool
+		if t, ok = names.QTypeToInt(strings.ToUpper(typ)); !ok {
+			n, err := strconv.ParseUint(typ, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("unknown type %s", typ)
+			}
+			t = uint16(n)
+		}
+	}
+	msg := message.New(header.NewQueryHeader(true), []query.Query{query.New(l, names.QTYPE(t))}, nil, nil, nil)
+	if jsonFlag(cd) {
+		msg.Header.Flags[1] |= 0x10
+	}
+	if jsonFlag(do) {
+		msg.SetOPT(&record.OPT{UDPSize: maxHTTPMessage, DO: true})
+	}
+	return msg.Encode(), nil
+}
+
+// jsonFlag reports whether a boolean parameter of a JSON request is set
+func jsonFlag(v string) bool {
+	return v == "1" || strings.EqualFold(v, "true")
+}
+
+// jsonMessage is a response in the JSON format
+type jsonMessage struct {
+	Status     uint16
+	TC         bool
+	RD         bool
+	RA         bool
+	AD         bool
+	CD         bool
+	Question   []jsonQuestion
+	Answer     []jsonRecord `json:",omitempty"`
+	Authority  []jsonRecord `json:",omitempty"`
+	Additional []jsonRecord `json:",omitempty"`
+}
+
+type jsonQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type jsonRecord struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32
+	Data string `json:"data"`
+}
+
+// newJSONMessage converts msg to the JSON format. Records are given in presentation format and the OPT record is left out.
+func newJSONMessage(msg *message.Message) *jsonMessage {
+	h := msg.Header
+	m := &jsonMessage{
+		Status:   msg.ResponseCode(),
+		TC:       h.Truncated(),
+		RD:       h.RecursionDesired(),
+		RA:       h.RecursionAvailable(),
+		AD:       h.Flags[1]&0x20 != 0,
+		CD:       h.Flags[1]&0x10 != 0,
+		Question: make([]jsonQuestion, len(msg.Questions)),
+	}
+	for i, q := range msg.Questions {
+		m.Question[i] = jsonQuestion{q.Name.String(), uint16(q.Type)}
+	}
+	m.Answer = jsonRecords(msg.Answers)
+	m.Authority = jsonRecords(msg.Authorities)
+	m.Additional = jsonRecords(msg.Additional)
+	return m
+}
+
+func jsonRecords(rs []response.Response) []jsonRecord {
+	var out []jsonRecord
+	for _, r := range rs {
+		if r.Type == names.OPT {
+			continue
+		}
+		rec := r.Record
+		if rec == nil {
+			if rec = record.New(r.Type); rec != nil && rec.Decode(r.Data, 0, len(r.Data)) != nil {
+				rec = nil
+			}
+		}
+		data := fmt.Sprintf("\\# %d %s", len(r.Data), hex.EncodeToString(r.Data)) // RFC 3597 section 5
+		if rec != nil {
+			data = rec.String()
+		}
+		out = append(out, jsonRecord{r.Name.String(), uint16(r.Type), r.TTL, data})
+	}
+	return out
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// httpsHandler answers A queries with two records and all other queries with NXDOMAIN and an SOA record
+func httpsHandler(w ResponseWriter, req *message.Message) {
+	q := req.Questions[0]
+	h := header.NewAnswerHeader(req.Header.ID, true, req.Header.RecursionDesired())
+	if q.Type != names.QTYPE(names.A) {
+		h.SetResponseCode(dnserror.NameError)
+		soa := response.FromRecord(label.Label{"example", "com"}, 120, &record.SOA{MName: label.Label{"ns", "example", "com"}, RName: label.Label{"admin", "example", "com"}, Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, Minimum: 60})
+		w.WriteMsg(message.New(h, req.Questions, nil, []response.Response{soa}, nil)) //nolint: errcheck
+		return
+	}
+	answers := []response.Response{
+		response.FromRecord(q.Name, 300, &record.A{IPv4: [4]byte{10, 0, 0, 1}}),
+		response.FromRecord(q.Name, 60, &record.A{IPv4: [4]byte{10, 0, 0, 2}}),
+	}
+	w.WriteMsg(message.New(h, req.Questions, answers, nil, nil)) //nolint: errcheck
+}
+
+func wireQuery(t names.TYPE) []byte {
+	msg := newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(t)))
+	msg.Header.ID = [2]byte{}
+	return msg.Encode()
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	s := &Server{Handler: HandlerFunc(httpsHandler)}
+	tests := []struct {
+		name             string
+		method           string
+		target           string
+		contentType      string
+		body             []byte
+		wantStatus       int
+		wantContentType  string
+		wantCacheControl string
+		wantRCode        uint8
+		wantAnswers      int
+	}{
+		{"GET", http.MethodGet, "/dns-query?dns=" + base64.RawURLEncoding.EncodeToString(wireQuery(names.A)), "", nil, http.StatusOK, MediaType, "max-age=60", dnserror.NoError, 2},
+		{"GET padded", http.MethodGet, "/dns-query?dns=" + base64.URLEncoding.EncodeToString(wireQuery(names.A)), "", nil, http.StatusOK, MediaType, "max-age=60", dnserror.NoError, 2},
+		{"POST", http.MethodPost, "/dns-query", MediaType, wireQuery(names.A), http.StatusOK, MediaType, "max-age=60", dnserror.NoError, 2},
+		{"Negative answer", http.MethodPost, "/dns-query", MediaType, wireQuery(names.MX), http.StatusOK, MediaType, "max-age=120", dnserror.NameError, 0},
+		{"JSON", http.MethodGet, "/dns-query?name=example.com", "", nil, http.StatusOK, JSONMediaType, "max-age=60", dnserror.NoError, 2},
+		{"JSON type", http.MethodGet, "/dns-query?name=example.com&type=mx", "", nil, http.StatusOK, JSONMediaType, "max-age=120", dnserror.NameError, 0},
+		{"JSON type number", http.MethodGet, "/dns-query?name=example.com&type=1", "", nil, http.StatusOK, JSONMediaType, "max-age=60", dnserror.NoError, 2},
+		{"JSON invalid type", http.MethodGet, "/dns-query?name=example.com&type=XYZ", "", nil, http.StatusBadRequest, "", "", 0, 0},
+		{"JSON invalid name", http.MethodGet, "/dns-query?name=exa%20mple.com", "", nil, http.StatusBadRequest, "", "", 0, 0},
+		{"Missing parameter", http.MethodGet, "/dns-query", "", nil, http.StatusBadRequest, "", "", 0, 0},
+		{"Invalid base64", http.MethodGet, "/dns-query?dns=%%%", "", nil, http.StatusBadRequest, "", "", 0, 0},
+		{"Invalid message", http.MethodPost, "/dns-query", MediaType, []byte{1, 2, 3}, http.StatusBadRequest, "", "", 0, 0},
+		{"Content type parameter", http.MethodPost, "/dns-query", "Application/DNS-Message; charset=binary", wireQuery(names.A), http.StatusOK, MediaType, "max-age=60", dnserror.NoError, 2},
+		{"Content type", http.MethodPost, "/dns-query", "text/plain", wireQuery(names.A), http.StatusUnsupportedMediaType, "", "", 0, 0},
+		{"Method", http.MethodPut, "/dns-query", MediaType, wireQuery(names.A), http.StatusMethodNotAllowed, "", "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.wantContentType {
+				t.Errorf("Content-Type = %s, want %s", ct, tt.wantContentType)
+			}
+			if cc := rec.Header().Get("Cache-Control"); cc != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %s, want %s", cc, tt.wantCacheControl)
+			}
+			if tt.wantContentType == JSONMediaType {
+				var m jsonMessage
+				if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+					t.Fatal(err)
+				}
+				if m.Status != uint16(tt.wantRCode) || len(m.Answer) != tt.wantAnswers || !m.RD || len(m.Question) != 1 || m.Question[0].Name != "example.com." {
+					t.Errorf("response = %+v", m)
+				}
+				return
+			}
+			m, err := message.Parse(rec.Body.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Header.ID != [2]byte{} || m.ResponseCode() != uint16(tt.wantRCode) || len(m.Answers) != tt.wantAnswers {
+				t.Errorf("response = %v", m)
+			}
+		})
+	}
+}
+
+func TestServer_ServeHTTP_Context(t *testing.T) {
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, req *message.Message) {
+		rcode := dnserror.NoError
+		if w.Context().Err() != nil {
+			rcode = dnserror.ServerFailure
+		}
+		w.WriteMsg(dnserror.New(rcode, false).Message(req.Header.ID, req.Questions...)) //nolint: errcheck
+	})}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodPost, DefaultHTTPSPath, bytes.NewReader(wireQuery(names.A))).WithContext(ctx)
+	r.Header.Set("Content-Type", MediaType)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	msg, err := message.Parse(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.ResponseCode() != dnserror.ServerFailure {
+		t.Error("handler did not receive the context of the HTTP request")
+	}
+}
+
+func Test_newJSONMessage(t *testing.T) {
+	req := newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.TXT)))
+	h := header.NewAnswerHeader(req.Header.ID, true, true)
+	answers := []response.Response{
+		response.FromRecord(label.Label{"example", "com"}, 60, &record.TXT{Strings: []string{"v=spf1 -all"}}),
+		response.New(label.Label{"example", "com"}, names.TXT, 60, []byte{3, 'a', 'b', 'c'}),
+		response.New(label.Label{"example", "com"}, names.TYPE(65280), 60, []byte{0xAB, 0xCD}),
+	}
+	msg := message.New(h, req.Questions, answers, nil, nil)
+	msg.SetOPT(&record.OPT{UDPSize: 1232})
+	got := newJSONMessage(msg)
+	want := []jsonRecord{
+		{"example.com.", uint16(names.TXT), 60, `"v=spf1 -all"`},
+		{"example.com.", uint16(names.TXT), 60, `"abc"`},
+		{"example.com.", 65280, 60, `\# 2 abcd`},
+	}
+	if len(got.Answer) != len(want) || got.Additional != nil {
+		t.Fatalf("newJSONMessage() = %+v", got)
+	}
+	for i := range want {
+		if got.Answer[i] != want[i] {
+			t.Errorf("newJSONMessage() answer %d = %+v, want %+v", i, got.Answer[i], want[i])
+		}
+	}
+}
+
+func TestServer_ServeHTTPS_NoConfig(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Server{}).ServeHTTPS(l, nil); err != ErrNoTLSConfig {
+		t.Errorf("Server.ServeHTTPS() error = %v, want %v", err, ErrNoTLSConfig)
+	}
+}
+
+func TestServer_ServeHTTPS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	der := writeCertificate(t, certFile, keyFile)
+	cert, err := LoadCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Handler: HandlerFunc(httpsHandler)}
+	errs := make(chan error, 1)
+	go func() { errs <- s.ServeHTTPS(l, cert.TLSConfig()) }()
+
+	roots := x509.NewCertPool()
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AddCert(parsed)
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{ServerName: "dns.test", RootCAs: roots}, ForceAttemptHTTP2: true},
+		Timeout:   5 * time.Second,
+	}
+	addr := "https://" + l.Addr().String()
+
+	resp, err := client.Post(addr+DefaultHTTPSPath, MediaType, bytes.NewReader(wireQuery(names.A)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close() //nolint: errcheck
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Fatalf("status %d via %s, want 200 via HTTP/2", resp.StatusCode, resp.Proto)
+	}
+	if m, err := message.Parse(body); err != nil || len(m.Answers) != 2 {
+		t.Errorf("response = %v, %v", m, err)
+	}
+
+	resp, err = client.Get(addr + "/other?name=example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() //nolint: errcheck
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status for other path = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	s.Close() //nolint: errcheck
+	select {
+	case err := <-errs:
+		if err != ErrServerClosed {
+			t.Errorf("ServeHTTPS() error = %v, want %v", err, ErrServerClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeHTTPS() did not return after Close()")
+	}
+	if _, err := client.Get(addr + "/dns-query?name=example.com"); err == nil {
+		t.Error("request after Close() succeeded")
+	}
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+)
+
+// QueryLog is a handler logging every request before passing it on
+type QueryLog struct {
+	Logger  *log.Logger
+	Handler Handler
+}
+
+// ServeDNS logs the client and questions of the request and passes it on to the handler
+func (l *QueryLog) ServeDNS(w ResponseWriter, req *message.Message) {
+	for _, q := range req.Questions {
+		t, ok := names.IntToQType(uint16(q.Type))
+		if !ok {
+			t = fmt.Sprintf("TYPE%d", q.Type) // RFC 3597 section 5
+		}
+		l.Logger.Printf("%s %s %s", w.RemoteAddr().String(), q.Name.String(), t)
+	}
+	l.Handler.ServeDNS(w, req)
+}
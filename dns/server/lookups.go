@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fossoreslp/go-dns/dns/cache"
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/passthrough"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-parser"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+// Zones is a lookup step answering questions from a set of local zones. The set can be replaced while the server is running.
+type Zones struct {
+	set atomic.Value // *parser.Set
+	// Minimal disables adding the NS records of the zone to the authority section and the addresses of target hosts to the additional section of answers
+	Minimal bool
+}
+
+// NewZones returns a lookup step serving the zones in set
+func NewZones(set *parser.Set) *Zones {
+	z := new(Zones)
+	z.Replace(set)
+	return z
+}
+
+// Set returns the current set of zones
+func (z *Zones) Set() *parser.Set {
+	set, _ := z.set.Load().(*parser.Set)
+	return set
+}
+
+// Replace atomically replaces the set of zones and indexes it. Lookups in progress continue to use the previous set.
+func (z *Zones) Replace(set *parser.Set) {
+	if set != nil {
+		set.Index()
+	}
+	z.set.Store(set)
+}
+
+// Lookup answers q if its name belongs to one of the local zones. Names at or below a zone cut result in referrals to the delegated zone.
+// Names without records of the requested type result in negative answers (RFC 2308).
+// Names that do not exist are answered from matching wildcards. Without a wildcard they are only answered for exclusive zones and passed on otherwise.
+func (z *Zones) Lookup(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+	set := z.Set()
+	if set == nil {
+		return nil, dnserror.Success()
+	}
+	zone, n := parser.FindMatchingZone(q.Name, set)
+	if zone == nil {
+		return nil, dnserror.Success()
+	}
+	if ns, cut := zone.Delegation(q.Name, n); len(ns.Records) > 0 {
+		return referral(set, q.Name[cut:], ns), dnserror.Success()
+	}
+	e := parser.FindMatchingEntry(q.Name, zone, n)
+	exists := e != nil || zone.IsEmptyNonTerminal(parser.Key(q.Name, n))
+	if !exists {
+		e = zone.Wildcard(q.Name, n) // Records synthesised from a wildcard use the name of the question as their owner
+	}
+	if e == nil && !exists {
+		if !zone.Exclusive {
+			return nil, dnserror.Success()
+		}
+		return &Result{Authority: negative(zone, q.Name[n:]), Authoritative: true, RCode: dnserror.NameError}, dnserror.Success()
+	}
+	var rs parser.RRSet
+	if e != nil {
+		rs = e.GetRecordsOfType(q.Type)
+		if len(rs.Records) == 0 && q.Type != names.QTYPE_ANY {
+			rs = (*e)[names.CNAME] // The chain resolves the target of the alias
+		}
+	}
+	if len(rs.Records) == 0 {
+		return &Result{Authority: negative(zone, q.Name[n:]), Authoritative: true}, dnserror.Success()
+	}
+	responses := make([]response.Response, 0, len(rs.Records))
+	for _, r := range rs.Records {
+		responses = append(responses, response.FromRecord(q.Name, rs.TTL, r))
+	}
+	res := &Result{Answers: responses, Authoritative: true}
+	if !z.Minimal {
+		if n != 0 || q.Type != names.QTYPE(names.NS) {
+			res.Authority = nameservers(zone, q.Name[n:])
+		}
+		res.Additional = additional(set, append(res.Answers, res.Authority...), nil)
+	}
+	return res, dnserror.Success()
+}
+
+// referral returns a non-authoritative answer pointing to the nameservers ns of the delegated zone name.
+// The addresses of the nameservers are added as glue regardless of the minimal responses setting.
+func referral(set *parser.Set, name label.Label, ns parser.RRSet) *Result {
+	authority := make([]response.Response, 0, len(ns.Records))
+	for _, r := range ns.Records {
+		authority = append(authority, response.FromRecord(name, ns.TTL, r))
+	}
+	return &Result{Authority: authority, Additional: additional(set, authority, name)}
+}
+
+// nameservers returns the NS records at the apex of zone
+func nameservers(zone *parser.Zone, name label.Label) []response.Response {
+	rs := zone.Entries[parser.Apex][names.NS]
+	out := make([]response.Response, 0, len(rs.Records))
+	for _, r := range rs.Records {
+		out = append(out, response.FromRecord(name, rs.TTL, r))
+	}
+	return out
+}
+
+// additional returns the A and AAAA records available in set for the hosts named by the MX, NS and SRV records in rs (RFC 1035 section 3.3).
+// Hosts at or below a zone cut belong to the delegated zone and are skipped unless they are glue for the referral to cut.
+func additional(set *parser.Set, rs []response.Response, cut label.Label) []response.Response {
+	var out []response.Response
+	seen := make(map[string]bool)
+	for _, r := range rs {
+		var host label.Label
+		switch rec := r.Record.(type) {
+		case *record.MX:
+			host = rec.Name
+		case *record.NS:
+			host = rec.Label
+		case *record.SRV:
+			host = rec.Host
+		}
+		key := strings.ToLower(host.String())
+		if len(host) == 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		zone, n := parser.FindMatchingZone(host, set)
+		if zone == nil {
+			continue
+		}
+		if ns, i := zone.Delegation(host, n); len(ns.Records) > 0 && !equalNames(host[i:], cut) {
+			continue
+		}
+		e := parser.FindMatchingEntry(host, zone, n)
+		if e == nil {
+			continue
+		}
+		for _, t := range []names.TYPE{names.A, names.AAAA} {
+			set := (*e)[t]
+			for _, a := range set.Records {
+				out = append(out, response.FromRecord(host, set.TTL, a))
+			}
+		}
+	}
+	return out
+}
+
+// negative returns the authority section of a negative answer from zone. It contains the SOA record of the zone using the lower of its TTL and its minimum field as the TTL (RFC 2308 section 3).
+func negative(zone *parser.Zone, name label.Label) []response.Response {
+	soa, ttl := zone.SOA()
+	if soa == nil {
+		return nil
+	}
+	if soa.Minimum < ttl {
+		ttl = soa.Minimum
+	}
+	return []response.Response{response.FromRecord(name, ttl, soa)}
+}
+
+// Cache is a lookup step answering questions from the record cache. A cached CNAME record is returned if there are no records of the requested type.
+func Cache() Lookup {
+	return LookupFunc(func(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+		resp := cache.GetRecords(q.Name, q.Type)
+		if resp == nil && q.Type != names.QTYPE_ANY {
+			resp = cache.GetRecords(q.Name, names.QTYPE(names.CNAME))
+		}
+		if resp == nil {
+			return nil, dnserror.Success()
+		}
+		return &Result{Answers: resp}, dnserror.Success()
+	})
+}
+
+// Passthrough is a lookup step resolving questions with the upstream resolvers of r. Successful answers are added to the cache if store is set.
+func Passthrough(r *passthrough.Resolver, store bool) Lookup {
+	return LookupFunc(func(ctx context.Context, q query.Query) (*Result, dnserror.Error) {
+		resp, dnserr := r.Resolve(ctx, q)
+		if dnserr.IsError() {
+			return nil, dnserr
+		}
+		if store {
+			cache.Cache(resp)
+		}
+		return &Result{Answers: resp}, dnserror.Success()
+	})
+}
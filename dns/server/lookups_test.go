@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-parser"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+)
+
+func testZones() *Zones {
+	soa := &record.SOA{MName: label.Label{"ns", "example", "com"}, RName: label.Label{"hostmaster", "example", "com"}, Serial: 1, Refresh: 7200, Retry: 3600, Expire: 86400, Minimum: 300}
+	return NewZones(&parser.Set{
+		"example.com": parser.Zone{Exclusive: true, Entries: map[string]parser.Entry{
+			parser.Apex: {names.SOA: {TTL: 3600, Records: []record.Record{soa}}},
+			"www":       {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, 1}}}}},
+			"a.b":       {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, 2}}}}},
+		}},
+		"example.org": parser.Zone{Exclusive: false, Entries: map[string]parser.Entry{
+			"www": {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 1, 1}}}}},
+		}},
+	})
+}
+
+func TestZones_Lookup(t *testing.T) {
+	z := testZones()
+	tests := []struct {
+		name          string
+		q             query.Query
+		wantNil       bool
+		wantRCode     uint8
+		wantAnswers   int
+		wantAuthority int
+		wantTTL       uint32
+	}{
+		{"Answer", query.New(label.Label{"www", "example", "com"}, names.QTYPE(names.A)), false, dnserror.NoError, 1, 0, 600},
+		{"Answer with different case", query.New(label.Label{"WWW", "Example", "com"}, names.QTYPE(names.A)), false, dnserror.NoError, 1, 0, 600},
+		{"No data", query.New(label.Label{"www", "example", "com"}, names.QTYPE(names.AAAA)), false, dnserror.NoError, 0, 1, 300},
+		{"No data at apex", query.New(label.Label{"example", "com"}, names.QTYPE(names.A)), false, dnserror.NoError, 0, 1, 300},
+		{"Empty non-terminal", query.New(label.Label{"b", "example", "com"}, names.QTYPE(names.A)), false, dnserror.NoError, 0, 1, 300},
+		{"Name error", query.New(label.Label{"nope", "example", "com"}, names.QTYPE(names.A)), false, dnserror.NameError, 0, 1, 300},
+		{"Name error below existing name", query.New(label.Label{"x", "www", "example", "com"}, names.QTYPE(names.A)), false, dnserror.NameError, 0, 1, 300},
+		{"Non-exclusive zone answer", query.New(label.Label{"www", "example", "org"}, names.QTYPE(names.A)), false, dnserror.NoError, 1, 0, 600},
+		{"Non-exclusive zone without SOA", query.New(label.Label{"www", "example", "org"}, names.QTYPE(names.MX)), false, dnserror.NoError, 0, 0, 0},
+		{"Non-exclusive zone passes on", query.New(label.Label{"nope", "example", "org"}, names.QTYPE(names.A)), true, dnserror.NoError, 0, 0, 0},
+		{"Outside of zones", query.New(label.Label{"example", "net"}, names.QTYPE(names.A)), true, dnserror.NoError, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := z.Lookup(context.Background(), tt.q)
+			if err.IsError() {
+				t.Fatalf("Zones.Lookup() error = %v", err)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("Zones.Lookup() = %v, want nil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if got.RCode != tt.wantRCode || len(got.Answers) != tt.wantAnswers || len(got.Authority) != tt.wantAuthority || !got.Authoritative {
+				t.Fatalf("Zones.Lookup() = %+v, want RCode %d, %d answers and %d authority records", got, tt.wantRCode, tt.wantAnswers, tt.wantAuthority)
+			}
+			for _, r := range append(got.Answers, got.Authority...) {
+				if r.TTL != tt.wantTTL {
+					t.Errorf("Zones.Lookup() TTL = %d, want %d", r.TTL, tt.wantTTL)
+				}
+			}
+			for _, r := range got.Authority {
+				if r.Type != names.SOA || r.Name.String() != "example.com." {
+					t.Errorf("Zones.Lookup() authority = %s %d, want SOA of example.com.", r.Name.String(), r.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestZones_ServeDNS_NameError(t *testing.T) {
+	w := new(recorder)
+	NewChain(testZones()).ServeDNS(w, newRequest(query.New(label.Label{"nope", "example", "com"}, names.QTYPE(names.A))))
+	if w.msg.Header.ResponseCode() != dnserror.NameError || !w.msg.Header.AuthoritativeAnswer() {
+		t.Errorf("Chain.ServeDNS() RCode = %d, AA = %v, want authoritative name error", w.msg.Header.ResponseCode(), w.msg.Header.AuthoritativeAnswer())
+	}
+	if w.msg.Header.NSCount != 1 || len(w.msg.Authorities) != 1 {
+		t.Errorf("Chain.ServeDNS() authority = %d records, want the SOA record", len(w.msg.Authorities))
+	}
+}
+
+func TestZones_Lookup_Additional(t *testing.T) {
+	ns := label.Label{"ns", "example", "com"}
+	mail := label.Label{"mail", "example", "com"}
+	sip := label.Label{"www", "example", "org"}
+	set := &parser.Set{
+		"example.com": parser.Zone{Exclusive: true, Entries: map[string]parser.Entry{
+			parser.Apex: {
+				names.NS: {TTL: 86400, Records: []record.Record{&record.NS{Label: ns}, &record.NS{Label: label.Label{"ns", "example", "net"}}}},
+				names.MX: {TTL: 3600, Records: []record.Record{&record.MX{Priority: 10, Name: mail}, &record.MX{Priority: 20, Name: mail}}},
+			},
+			"ns":        {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, 1}}}}},
+			"mail":      {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, 2}}}}, names.AAAA: {TTL: 600, Records: []record.Record{&record.AAAA{IPv6: [16]byte{0xfd, 15: 2}}}}},
+			"_sip._tcp": {names.SRV: {TTL: 600, Records: []record.Record{&record.SRV{Priority: 1, Weight: 1, Port: 5060, Host: sip}}}},
+			"www":       {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 0, 3}}}}},
+			"delegated": {names.MX: {TTL: 3600, Records: []record.Record{&record.MX{Priority: 10, Name: label.Label{"mail", "sub", "example", "com"}}}}},
+			"sub":       {names.NS: {TTL: 3600, Records: []record.Record{&record.NS{Label: label.Label{"ns", "sub", "example", "com"}}}}},
+			"ns.sub":    {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 2, 1}}}}},
+			"mail.sub":  {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 2, 2}}}}},
+		}},
+		"example.org": parser.Zone{Exclusive: true, Entries: map[string]parser.Entry{
+			"www": {names.A: {TTL: 600, Records: []record.Record{&record.A{IPv4: [4]byte{10, 0, 1, 1}}}}},
+		}},
+	}
+	tests := []struct {
+		name           string
+		q              query.Query
+		minimal        bool
+		wantAuthority  int
+		wantAdditional []string
+	}{
+		{"MX", query.New(label.Label{"example", "com"}, names.QTYPE(names.MX)), false, 2, []string{"mail.example.com.", "mail.example.com.", "ns.example.com."}},
+		{"NS at apex", query.New(label.Label{"example", "com"}, names.QTYPE(names.NS)), false, 0, []string{"ns.example.com."}},
+		{"SRV in other zone", query.New(label.Label{"_sip", "_tcp", "example", "com"}, names.QTYPE(names.SRV)), false, 2, []string{"www.example.org.", "ns.example.com."}},
+		{"A", query.New(label.Label{"www", "example", "com"}, names.QTYPE(names.A)), false, 2, []string{"ns.example.com."}},
+		{"MX in delegated zone", query.New(label.Label{"delegated", "example", "com"}, names.QTYPE(names.MX)), false, 2, []string{"ns.example.com."}},
+		{"Glue", query.New(label.Label{"www", "sub", "example", "com"}, names.QTYPE(names.A)), false, 1, []string{"ns.sub.example.com."}},
+		{"Minimal", query.New(label.Label{"example", "com"}, names.QTYPE(names.MX)), true, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z := NewZones(set)
+			z.Minimal = tt.minimal
+			got, err := z.Lookup(context.Background(), tt.q)
+			if err.IsError() || got == nil {
+				t.Fatalf("Zones.Lookup() = %v, %v", got, err)
+			}
+			if len(got.Authority) != tt.wantAuthority {
+				t.Errorf("Zones.Lookup() authority = %d records, want %d", len(got.Authority), tt.wantAuthority)
+			}
+			var additional []string
+			for _, r := range got.Additional {
+				additional = append(additional, r.Name.String())
+			}
+			if !reflect.DeepEqual(additional, tt.wantAdditional) {
+				t.Errorf("Zones.Lookup() additional = %v, want %v", additional, tt.wantAdditional)
+			}
+		})
+	}
+}
+
+// wildcardZone is the example zone of RFC 4592 section 2.2.1
+const wildcardZone = `$ORIGIN example.
+@                 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 86400 300
+                  3600    NS  ns.example.com.
+*                 3600    TXT "this is a wildcard"
+*                 3600    MX  10 host1
+sub.*             3600    TXT "this is not a wildcard"
+host1             3600    A   192.0.2.1
+_ssh._tcp.host1   3600    SRV 10 60 22 host1
+_ssh._tcp.host2   3600    SRV 10 60 22 host2
+`
+
+func TestZones_Lookup_Wildcard(t *testing.T) {
+	set, err := parser.ParseMaster(strings.NewReader(wildcardZone), "example.zone", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := NewZones(set)
+	z.Minimal = true
+	tests := []struct {
+		name        string
+		q           query.Query
+		wantRCode   uint8
+		wantAnswers int
+	}{
+		{"Synthesised MX", query.New(label.Label{"host3", "example"}, names.QTYPE(names.MX)), dnserror.NoError, 1},
+		{"Synthesised no data", query.New(label.Label{"host3", "example"}, names.QTYPE(names.A)), dnserror.NoError, 0},
+		{"Multiple labels", query.New(label.Label{"foo", "bar", "example"}, names.QTYPE(names.TXT)), dnserror.NoError, 1},
+		{"Existing name", query.New(label.Label{"host1", "example"}, names.QTYPE(names.MX)), dnserror.NoError, 0},
+		{"Wildcard as label", query.New(label.Label{"sub", "*", "example"}, names.QTYPE(names.MX)), dnserror.NoError, 0},
+		{"Closest encloser without wildcard", query.New(label.Label{"_telnet", "_tcp", "host1", "example"}, names.QTYPE(names.SRV)), dnserror.NameError, 0},
+		{"Empty non-terminal blocks wildcard", query.New(label.Label{"_tcp", "host2", "example"}, names.QTYPE(names.TXT)), dnserror.NoError, 0},
+		{"Closest encloser is the wildcard", query.New(label.Label{"ghost", "*", "example"}, names.QTYPE(names.MX)), dnserror.NameError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := z.Lookup(context.Background(), tt.q)
+			if err.IsError() || got == nil {
+				t.Fatalf("Zones.Lookup() = %v, %v", got, err)
+			}
+			if got.RCode != tt.wantRCode || len(got.Answers) != tt.wantAnswers {
+				t.Fatalf("Zones.Lookup() = RCode %d with %d answers, want RCode %d with %d answers", got.RCode, len(got.Answers), tt.wantRCode, tt.wantAnswers)
+			}
+			for _, r := range got.Answers {
+				if !reflect.DeepEqual(r.Name, tt.q.Name) {
+					t.Errorf("Zones.Lookup() answer owner = %s, want %s", r.Name.String(), tt.q.Name.String())
+				}
+			}
+		})
+	}
+}
+
+const delegationZone = `$ORIGIN example.
+@             3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 86400 300
+              3600    NS  ns.example.com.
+*             3600    A   192.0.2.2
+sub           3600    NS  ns1.sub
+              3600    NS  ns.example.net.
+ns1.sub       3600    A   192.0.2.53
+www.sub       3600    A   192.0.2.80
+deep.a        3600    NS  ns.example.net.
+`
+
+func TestZones_Lookup_Delegation(t *testing.T) {
+	set, err := parser.ParseMaster(strings.NewReader(delegationZone), "example.zone", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := NewZones(set)
+	z.Minimal = true
+	tests := []struct {
+		name           string
+		q              query.Query
+		wantReferral   bool
+		wantCut        string
+		wantAdditional int
+	}{
+		{"Below cut", query.New(label.Label{"www", "sub", "example"}, names.QTYPE(names.A)), true, "sub.example.", 1},
+		{"Glue name", query.New(label.Label{"ns1", "sub", "example"}, names.QTYPE(names.A)), true, "sub.example.", 1},
+		{"At cut", query.New(label.Label{"sub", "example"}, names.QTYPE(names.NS)), true, "sub.example.", 1},
+		{"Unknown name below cut", query.New(label.Label{"nope", "sub", "example"}, names.QTYPE(names.A)), true, "sub.example.", 1},
+		{"Cut below empty non-terminal", query.New(label.Label{"x", "deep", "a", "example"}, names.QTYPE(names.A)), true, "deep.a.example.", 0},
+		{"Empty non-terminal above cut", query.New(label.Label{"a", "example"}, names.QTYPE(names.A)), false, "", 0},
+		{"Wildcard", query.New(label.Label{"other", "example"}, names.QTYPE(names.A)), false, "", 0},
+		{"Apex", query.New(label.Label{"example"}, names.QTYPE(names.NS)), false, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := z.Lookup(context.Background(), tt.q)
+			if err.IsError() || got == nil {
+				t.Fatalf("Zones.Lookup() = %v, %v", got, err)
+			}
+			if tt.wantReferral != !got.Authoritative {
+				t.Fatalf("Zones.Lookup() AA = %v, want referral %v", got.Authoritative, tt.wantReferral)
+			}
+			if !tt.wantReferral {
+				return
+			}
+			if len(got.Answers) != 0 || got.RCode != dnserror.NoError || len(got.Authority) == 0 {
+				t.Fatalf("Zones.Lookup() = %+v, want referral", got)
+			}
+			for _, r := range got.Authority {
+				if r.Type != names.NS || r.Name.String() != tt.wantCut {
+					t.Errorf("Zones.Lookup() authority = %s %d, want NS of %s", r.Name.String(), r.Type, tt.wantCut)
+				}
+			}
+			if len(got.Additional) != tt.wantAdditional {
+				t.Errorf("Zones.Lookup() additional = %d records, want %d", len(got.Additional), tt.wantAdditional)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+)
+
+// ServeMux is a DNS request multiplexer. It routes requests to the handler registered for the longest zone matching the name of the first question.
+type ServeMux struct {
+	mu    sync.RWMutex
+	zones map[string]Handler
+}
+
+// NewServeMux returns a new, empty ServeMux
+func NewServeMux() *ServeMux {
+	return &ServeMux{zones: make(map[string]Handler)}
+}
+
+// Handle registers the handler for zone. The zone "." matches every request not matched by a more specific zone.
+func (m *ServeMux) Handle(zone string, h Handler) {
+	if h == nil {
+		panic("server: nil handler")
+	}
+	m.mu.Lock()
+	m.zones[normalizeZone(zone)] = h
+	m.mu.Unlock()
+}
+
+// HandleFunc registers the handler function for zone
+func (m *ServeMux) HandleFunc(zone string, f func(ResponseWriter, *message.Message)) {
+	m.Handle(zone, HandlerFunc(f))
+}
+
+// Handler returns the handler for the zone matching name and nil if there is none
+func (m *ServeMux) Handler(name label.Label) Handler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := 0; i <= len(name); i++ {
+		if h, ok := m.zones[strings.ToLower(strings.Join(name[i:], "."))]; ok {
+			return h
+		}
+	}
+	return nil
+}
+
+// ServeDNS dispatches the request to the matching handler or refuses it if no zone matches
+func (m *ServeMux) ServeDNS(w ResponseWriter, req *message.Message) {
+	var h Handler
+	if len(req.Questions) > 0 {
+		h = m.Handler(req.Questions[0].Name)
+	}
+	if h == nil {
+		w.WriteMsg(dnserror.New(dnserror.Refused, false).Message(req.Header.ID, req.Questions...)) //nolint: errcheck
+		return
+	}
+	h.ServeDNS(w, req)
+}
+
+func normalizeZone(zone string) string {
+	return strings.ToLower(strings.TrimSuffix(zone, "."))
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+)
+
+func TestServeMux_ServeDNS(t *testing.T) {
+	var got string
+	named := func(name string) HandlerFunc {
+		return func(w ResponseWriter, req *message.Message) {
+			got = name
+		}
+	}
+	mux := NewServeMux()
+	mux.Handle("example.com.", named("example.com"))
+	mux.Handle("dev.example.com", named("dev.example.com"))
+	mux.Handle("n", named("n"))
+
+	root := NewServeMux()
+	root.Handle(".", named("root"))
+	root.Handle("n", named("n"))
+
+	tests := []struct {
+		name  string
+		mux   *ServeMux
+		qname label.Label
+		want  string
+	}{
+		{"Exact zone", mux, label.Label{"example", "com"}, "example.com"},
+		{"Below zone", mux, label.Label{"www", "example", "com"}, "example.com"},
+		{"Longest match", mux, label.Label{"branch", "dev", "example", "com"}, "dev.example.com"},
+		{"Case insensitive", mux, label.Label{"WWW", "Example", "COM"}, "example.com"},
+		{"Partial label", mux, label.Label{"ns", "nn"}, ""},
+		{"Default zone", root, label.Label{"example", "org"}, "root"},
+		{"Specific before default", root, label.Label{"ns", "n"}, "n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			w := new(recorder)
+			tt.mux.ServeDNS(w, newRequest(query.New(tt.qname, names.QTYPE(names.A))))
+			if got != tt.want {
+				t.Errorf("ServeMux.ServeDNS() routed to %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && (w.msg == nil || w.msg.Header.ResponseCode() != dnserror.Refused) {
+				t.Errorf("ServeMux.ServeDNS() did not refuse unmatched request")
+			}
+		})
+	}
+}
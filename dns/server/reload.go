@@ -0,0 +1,77 @@
+package server
+
+import (
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/record-parser"
+)
+
+// Reloader replaces the set of zones served by Zones with a freshly loaded one
+type Reloader struct {
+	Zones *Zones
+	// Load returns the new set of zones. If it fails, the current set is kept.
+	Load func() (*parser.Set, error)
+	// Log is called with the result of every reload. It may be nil.
+	Log func(error)
+}
+
+// Reload loads the zones and swaps them in if loading succeeded
+func (r *Reloader) Reload() error {
+	set, err := r.Load()
+	if err == nil {
+		r.Zones.Replace(set)
+	}
+	if r.Log != nil {
+		r.Log(err)
+	}
+	return err
+}
+
+// ReloadOnSignal reloads the zones whenever one of sigs is received. Calling the returned function stops it.
+func (r *Reloader) ReloadOnSignal(sigs ...os.Signal) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c:
+				r.Reload() //nolint: errcheck
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
+
+// Watch checks the file at path every interval and reloads the zones when its modification time or size changed. Calling the returned function stops it.
+func (r *Reloader) Watch(path string, interval time.Duration) (stop func()) {
+	last, _ := os.Stat(path) //nolint: errcheck
+	t := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				info, err := os.Stat(path)
+				if err != nil || last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+					continue
+				}
+				last = info
+				r.Reload() //nolint: errcheck
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		t.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-parser"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	old := &parser.Set{"example.com": parser.Zone{Exclusive: true}}
+	updated := &parser.Set{"example.org": parser.Zone{Exclusive: true}}
+	tests := []struct {
+		name    string
+		load    func() (*parser.Set, error)
+		want    *parser.Set
+		wantErr bool
+	}{
+		{"Success", func() (*parser.Set, error) { return updated, nil }, updated, false},
+		{"Failure keeps zones", func() (*parser.Set, error) { return nil, errors.New("invalid zone") }, old, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged error
+			r := &Reloader{Zones: NewZones(old), Load: tt.load, Log: func(err error) { logged = err }}
+			if err := r.Reload(); (err != nil) != tt.wantErr || logged != err {
+				t.Errorf("Reloader.Reload() error = %v, logged %v, wantErr %v", err, logged, tt.wantErr)
+			}
+			if got := r.Zones.Set(); got != tt.want {
+				t.Errorf("Reloader.Reload() set = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloader_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.toml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("[com]\nexclusive = true\n[com.entries.example]\nA = [\"10.0.0.1\"]\n")
+	set, err := parser.LoadZonesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zones := NewZones(set)
+	logs := make(chan error, 10)
+	r := &Reloader{Zones: zones, Load: func() (*parser.Set, error) { return parser.LoadZonesFile(path) }, Log: func(err error) { logs <- err }}
+	defer r.Watch(path, 10*time.Millisecond)()
+
+	q := query.New(label.Label{"example", "com"}, names.QTYPE(names.A))
+	wait := func() error {
+		select {
+		case err := <-logs:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("zones were not reloaded")
+			return nil
+		}
+	}
+
+	write("[com]\nexclusive = true\n[com.entries.example]\nA = [\"10.0.0.1\", \"10.0.0.2\"]\n")
+	if err := wait(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if res, _ := zones.Lookup(context.Background(), q); res == nil || len(res.Answers) != 2 {
+		t.Fatalf("Zones.Lookup() after reload = %v, want 2 answers", res)
+	}
+
+	write("[com]\nexclusive = true\n[com.entries.example]\nA = [\"10.0.0\"]\n")
+	if err := wait(); err == nil {
+		t.Fatal("invalid zones file was accepted")
+	}
+	if res, _ := zones.Lookup(context.Background(), q); res == nil || len(res.Answers) != 2 {
+		t.Errorf("Zones.Lookup() after failed reload = %v, want previous 2 answers", res)
+	}
+}
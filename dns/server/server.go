@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+)
+
+// ErrServerClosed is returned by the Serve and ListenAndServe methods after a call to Close
+var ErrServerClosed = errors.New("server: Server closed")
+
+// DefaultIdleTimeout is used for TCP connections if Server.IdleTimeout is not set
+const DefaultIdleTimeout = 10 * time.Second
+
+// DefaultRequestTimeout is used as the time limit for answering a request if Server.RequestTimeout is not set
+const DefaultRequestTimeout = 5 * time.Second
+
+// DefaultMaxPipelined is used as the limit of concurrently answered requests per TCP connection if Server.MaxPipelined is not set
+const DefaultMaxPipelined = 32
+
+// DefaultUDPSize is used as the UDP buffer size if Server.UDPSize is not set
+const DefaultUDPSize = 1232
+
+// MinUDPSize is the payload size every client has to accept via UDP (RFC 1035 section 2.3.4)
+const MinUDPSize = 512
+
+// EDNSVersion is the highest EDNS version supported by the server
+const EDNSVersion = 0
+
+// Server is a DNS server answering requests received via UDP, TCP, TLS and HTTPS
+type Server struct {
+	// Addr is the address to listen on. Defaults to ":53".
+	Addr string
+	// Handler is invoked for every valid request
+	Handler Handler
+	// ReadTimeout is the maximum time to wait for a UDP request or for the remainder of a TCP request once its length has been received. Zero means no timeout.
+	ReadTimeout time.Duration
+	// WriteTimeout is the maximum time allowed for sending a response. Zero means no timeout.
+	WriteTimeout time.Duration
+	// IdleTimeout is the time a TCP connection is kept open while waiting for the next request. Defaults to DefaultIdleTimeout.
+	IdleTimeout time.Duration
+	// RequestTimeout is the maximum time the handler may spend on a request before its context is done. Defaults to DefaultRequestTimeout.
+	RequestTimeout time.Duration
+	// UDPSize is the size of the buffer used for reading UDP requests and the payload size advertised via EDNS. Defaults to DefaultUDPSize.
+	UDPSize int
+	// MaxTCPConnections limits the number of concurrent TCP connections. Additional connections are closed immediately. Zero means no limit.
+	MaxTCPConnections int
+	// MaxPipelined limits the number of requests answered concurrently on a single TCP connection.
+	// Further requests are not read until one of them is answered. Defaults to DefaultMaxPipelined.
+	MaxPipelined int
+	// HTTPSPath is the path DNS over HTTPS is served on by ListenAndServeHTTPS. Defaults to DefaultHTTPSPath.
+	HTTPSPath string
+	// Log is called with errors that occur while answering requests like invalid requests or responses that could not be sent. It may be nil.
+	Log func(error)
+
+	mu        sync.Mutex
+	packet    []net.PacketConn
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+	http      []*http.Server
+	closed    bool
+}
+
+// ListenAndServe listens on the UDP and TCP address s.Addr and serves incoming requests on both. It returns once either of them fails.
+func (s *Server) ListenAndServe() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":53"
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		conn.Close() //nolint: errcheck
+		return err
+	}
+	errs := make(chan error, 2)
+	go func() { errs <- s.ServeUDP(conn) }()
+	go func() { errs <- s.ServeTCP(l) }()
+	err = <-errs
+	s.Close() //nolint: errcheck
+	<-errs
+	return err
+}
+
+// Close stops the server and closes all of its listeners and connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var err error
+	for _, c := range s.packet {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for _, l := range s.listeners {
+		if lerr := l.Close(); lerr != nil && err == nil {
+			err = lerr
+		}
+	}
+	for c := range s.conns {
+		c.Close() //nolint: errcheck
+	}
+	for _, hs := range s.http {
+		if herr := hs.Close(); herr != nil && err == nil {
+			err = herr
+		}
+	}
+	s.packet, s.listeners, s.conns, s.http = nil, nil, nil, nil
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// logError passes err on to s.Log if it is set
+func (s *Server) logError(err error) {
+	if s.Log != nil {
+		s.Log(err)
+	}
+}
+
+// serve parses a single request and passes it on to the handler
+func (s *Server) serve(w ResponseWriter, data []byte) {
+	if req := s.parse(w, data); req != nil {
+		s.dispatch(w, req)
+	}
+}
+
+// parse parses a request and answers it with a format error if it is not a valid query. It returns nil if the request should not be processed any further.
+func (s *Server) parse(w ResponseWriter, data []byte) *message.Message {
+	req, err := message.Parse(data)
+	if err != nil {
+		s.logError(fmt.Errorf("invalid request from %s: %s", w.RemoteAddr().String(), err.Error()))
+		return nil
+	}
+	if req.Header.IsResponse() || req.Header.QuestionCount == 0 || countOPT(req) > 1 {
+		w.WriteMsg(dnserror.New(dnserror.FormatError, false).Message(req.Header.ID)) //nolint: errcheck
+		return nil
+	}
+	o := req.OPT()
+	if o != nil && o.Version != EDNSVersion {
+		resp := dnserror.New(dnserror.BadVersion, false).Message(req.Header.ID, req.Questions...)
+		resp.SetOPT(&record.OPT{UDPSize: uint16(s.udpSize()), ExtendedRCode: dnserror.BadVersion >> 4, Version: EDNSVersion})
+		w.WriteMsg(resp) //nolint: errcheck
+		return nil
+	}
+	// Clients must not send edns-tcp-keepalive with a timeout. The option is ignored if it is received via UDP (RFC 7828 section 3.3.1).
+	if o != nil && isStream(w) {
+		if k := o.Option(record.OptionTCPKeepalive); k != nil && len(k.Data) > 0 {
+			w.WriteMsg(dnserror.New(dnserror.FormatError, false).Message(req.Header.ID, req.Questions...)) //nolint: errcheck
+			return nil
+		}
+	}
+	return req
+}
+
+// isStream reports whether w sends responses over a TCP or TLS connection
+func isStream(w ResponseWriter) bool {
+	_, ok := w.(*tcpWriter)
+	return ok
+}
+
+// countOPT returns the number of OPT records in the additional section of msg
+func countOPT(msg *message.Message) int {
+	n := 0
+	for _, r := range msg.Additional {
+		if r.Type == names.OPT {
+			n++
+		}
+	}
+	return n
+}
+
+// dispatch passes a valid request on to the handler. Responses sent over TCP or TLS announce the idle timeout via edns-tcp-keepalive (RFC 7828).
+// The context of the request is limited to the request timeout.
+func (s *Server) dispatch(w ResponseWriter, req *message.Message) {
+	if o := req.OPT(); o != nil {
+		opt := &record.OPT{UDPSize: uint16(s.udpSize()), Version: EDNSVersion, DO: o.DO}
+		if isStream(w) {
+			// The timeout is given in units of 100 milliseconds
+			units := s.idleTimeout() / (100 * time.Millisecond)
+			if units > 0xFFFF {
+				units = 0xFFFF
+			}
+			timeout := make([]byte, 2)
+			binary.BigEndian.PutUint16(timeout, uint16(units))
+			opt.Options = []record.Option{{Code: record.OptionTCPKeepalive, Data: timeout}}
+		}
+		w = &ednsWriter{w, opt}
+	}
+	if s.Handler == nil {
+		w.WriteMsg(dnserror.New(dnserror.ServerFailure, false).Message(req.Header.ID, req.Questions...)) //nolint: errcheck
+		return
+	}
+	ctx, cancel := context.WithTimeout(w.Context(), s.requestTimeout())
+	defer cancel()
+	w = &contextWriter{w, ctx}
+	s.Handler.ServeDNS(w, req)
+}
+
+// idleTimeout returns the time a TCP connection is kept open while waiting for the next request
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout <= 0 {
+		return DefaultIdleTimeout
+	}
+	return s.IdleTimeout
+}
+
+// requestTimeout returns the maximum time spent on a single request
+func (s *Server) requestTimeout() time.Duration {
+	if s.RequestTimeout <= 0 {
+		return DefaultRequestTimeout
+	}
+	return s.RequestTimeout
+}
+
+// maxPipelined returns the number of requests answered concurrently on a single TCP connection
+func (s *Server) maxPipelined() int {
+	if s.MaxPipelined <= 0 {
+		return DefaultMaxPipelined
+	}
+	return s.MaxPipelined
+}
+
+func (s *Server) udpSize() int {
+	if s.UDPSize <= 0 {
+		return DefaultUDPSize
+	}
+	return s.UDPSize
+}
+
+// ednsWriter adds an OPT record to responses to requests that contained one (RFC 6891 section 7)
+type ednsWriter struct {
+	ResponseWriter
+	opt *record.OPT
+}
+
+func (w *ednsWriter) WriteMsg(msg *message.Message) error {
+	if msg != nil && msg.OPT() == nil {
+		o := *w.opt
+		msg.SetOPT(&o)
+	}
+	return w.ResponseWriter.WriteMsg(msg)
+}
+
+// contextWriter replaces the context of a request
+type contextWriter struct {
+	ResponseWriter
+	ctx context.Context
+}
+
+func (w *contextWriter) Context() context.Context {
+	return w.ctx
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/stream"
+)
+
+// ServeTCP accepts connections on l and serves the requests received on them (RFC 7766). It always returns a non-nil error and closes l.
+func (s *Server) ServeTCP(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close() //nolint: errcheck
+		return ErrServerClosed
+	}
+	s.listeners = append(s.listeners, l)
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.mu.Unlock()
+	defer l.Close() //nolint: errcheck
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !s.trackConn(c) {
+			c.Close() //nolint: errcheck
+			continue
+		}
+		go s.serveConn(c)
+	}
+}
+
+// trackConn registers c as an open connection and reports whether the connection limit allows serving it
+func (s *Server) trackConn(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.MaxTCPConnections > 0 && len(s.conns) >= s.MaxTCPConnections {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackConn(c net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+}
+
+// serveConn reads requests from c until the client closes the connection or it is idle for too long.
+// Requests are answered concurrently so that multiple pipelined queries can be processed at once. Reading pauses while s.MaxPipelined requests are in flight.
+func (s *Server) serveConn(c net.Conn) {
+	defer s.untrackConn(c)
+	defer c.Close() //nolint: errcheck
+
+	idle := s.idleTimeout()
+	w := &tcpWriter{srv: s, conn: c}
+	inflight := make(chan struct{}, s.maxPipelined())
+	var wg sync.WaitGroup
+	for {
+		inflight <- struct{}{}
+		c.SetReadDeadline(time.Now().Add(idle)) //nolint: errcheck
+		data, err := stream.Read(&deadlineReader{c, s.ReadTimeout})
+		if err != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inflight }()
+			s.serve(w, data)
+		}()
+	}
+	wg.Wait()
+}
+
+// deadlineReader moves the read deadline of a connection to the read timeout once the first bytes of a message have been received
+type deadlineReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	n, err := r.conn.Read(p)
+	if n > 0 && r.timeout > 0 {
+		r.conn.SetReadDeadline(time.Now().Add(r.timeout)) //nolint: errcheck
+		r.timeout = 0
+	}
+	return n, err
+}
+
+// tcpWriter sends length prefixed responses on a TCP connection
+type tcpWriter struct {
+	srv  *Server
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (w *tcpWriter) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
+
+func (w *tcpWriter) Context() context.Context {
+	return context.Background()
+}
+
+func (w *tcpWriter) WriteMsg(msg *message.Message) error {
+	if msg == nil {
+		return errors.New("cannot send empty message")
+	}
+	out := msg.Encode()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.srv.WriteTimeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.srv.WriteTimeout)) //nolint: errcheck
+	}
+	return stream.Write(w.conn, out)
+}
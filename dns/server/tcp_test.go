@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/stream"
+)
+
+func startTCP(t *testing.T, s *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeTCP(l)                //nolint: errcheck
+	t.Cleanup(func() { s.Close() }) //nolint: errcheck
+	return l.Addr().String()
+}
+
+func echoHandler(w ResponseWriter, req *message.Message) {
+	w.WriteMsg(message.New(header.NewAnswerHeader(req.Header.ID, true, false), req.Questions, nil, nil, nil)) //nolint: errcheck
+}
+
+func TestServer_ServeTCP(t *testing.T) {
+	addr := startTCP(t, &Server{Handler: HandlerFunc(echoHandler)})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()                                //nolint: errcheck
+	c.SetDeadline(time.Now().Add(5 * time.Second)) //nolint: errcheck
+
+	ids := make(map[[2]byte]bool)
+	for i := 0; i < 3; i++ {
+		req := newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.A)))
+		ids[req.Header.ID] = true
+		if err := stream.Write(c, req.Encode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		data, err := stream.Read(c)
+		if err != nil {
+			t.Fatalf("reading response %d failed: %v", i, err)
+		}
+		resp, err := message.Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ids[resp.Header.ID] {
+			t.Errorf("unexpected response ID %X", resp.Header.ID)
+		}
+		delete(ids, resp.Header.ID)
+	}
+}
+
+func TestServer_ServeTCP_MaxPipelined(t *testing.T) {
+	var active, peak int32
+	release := make(chan struct{})
+	h := HandlerFunc(func(w ResponseWriter, req *message.Message) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&active, -1)
+		echoHandler(w, req)
+	})
+	addr := startTCP(t, &Server{Handler: h, MaxPipelined: 2})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()                                //nolint: errcheck
+	c.SetDeadline(time.Now().Add(5 * time.Second)) //nolint: errcheck
+	for i := 0; i < 6; i++ {
+		if err := stream.Write(c, newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.A))).Encode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&active); n != 2 {
+		t.Errorf("%d requests in flight, want 2", n)
+	}
+	close(release)
+	for i := 0; i < 6; i++ {
+		if _, err := stream.Read(c); err != nil {
+			t.Fatalf("reading response %d failed: %v", i, err)
+		}
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("%d requests were answered concurrently, want at most 2", p)
+	}
+}
+
+func TestServer_ServeTCP_IdleTimeout(t *testing.T) {
+	addr := startTCP(t, &Server{Handler: HandlerFunc(echoHandler), IdleTimeout: 50 * time.Millisecond})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()                                //nolint: errcheck
+	c.SetDeadline(time.Now().Add(5 * time.Second)) //nolint: errcheck
+	if _, err := stream.Read(c); err == nil {
+		t.Error("idle connection was not closed by the server")
+	}
+}
+
+func TestServer_ServeTCP_MaxConnections(t *testing.T) {
+	addr := startTCP(t, &Server{Handler: HandlerFunc(echoHandler), MaxTCPConnections: 1})
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close() //nolint: errcheck
+	req := newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.A)))
+	first.SetDeadline(time.Now().Add(5 * time.Second)) //nolint: errcheck
+	if err := stream.Write(first, req.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Read(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()                                //nolint: errcheck
+	second.SetDeadline(time.Now().Add(5 * time.Second)) //nolint: errcheck
+	stream.Write(second, req.Encode())                  //nolint: errcheck
+	if _, err := stream.Read(second); err == nil {
+		t.Error("connection exceeding the limit was served")
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultTLSAddr is the address DNS over TLS is served on if Server.Addr is not set (RFC 7858 section 3.1)
+const DefaultTLSAddr = ":853"
+
+// ErrNoTLSConfig is returned by ServeTLS and ServeHTTPS if they are called without a TLS configuration
+var ErrNoTLSConfig = errors.New("server: missing TLS config")
+
+// ListenAndServeTLS listens on the TCP address s.Addr and serves DNS over TLS (RFC 7858) using config
+func (s *Server) ListenAndServeTLS(config *tls.Config) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultTLSAddr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(l, config)
+}
+
+// ServeTLS accepts TLS connections on l and serves the requests received on them like ServeTCP. It always returns a non-nil error and closes l.
+func (s *Server) ServeTLS(l net.Listener, config *tls.Config) error {
+	if config == nil {
+		l.Close() //nolint: errcheck
+		return ErrNoTLSConfig
+	}
+	return s.ServeTCP(tls.NewListener(l, config))
+}
+
+// CertificateCheckInterval is the minimum time between two checks whether the files of a Certificate changed
+const CertificateCheckInterval = time.Second
+
+// Certificate is a TLS certificate that is reloaded from its files once they change
+type Certificate struct {
+	CertFile string
+	KeyFile  string
+	// Log is called with the result of every reload. It may be nil.
+	Log func(error)
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+	checked  time.Time
+}
+
+// LoadCertificate loads the certificate and key from the PEM files certFile and keyFile
+func LoadCertificate(certFile, keyFile string) (*Certificate, error) {
+	c := &Certificate{CertFile: certFile, KeyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads the certificate from its files. The current certificate is kept if loading fails.
+func (c *Certificate) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reload(c.lastModified())
+}
+
+// reload loads the certificate whose files were last modified at modified. Failed files are not tried again until they change. c.mu has to be held.
+func (c *Certificate) reload(modified time.Time) error {
+	c.modified = modified
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err == nil {
+		c.cert = &cert
+	}
+	if c.Log != nil {
+		c.Log(err)
+	}
+	return err
+}
+
+// lastModified returns the latest modification time of the certificate and key file
+func (c *Certificate) lastModified() time.Time {
+	var t time.Time
+	for _, f := range []string{c.CertFile, c.KeyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(t) {
+			t = info.ModTime()
+		}
+	}
+	return t
+}
+
+// GetCertificate returns the current certificate. It is used as tls.Config.GetCertificate.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.checked) >= CertificateCheckInterval {
+		c.checked = now
+		if modified := c.lastModified(); !modified.Equal(c.modified) {
+			c.reload(modified) //nolint: errcheck
+		}
+	}
+	return c.cert, nil
+}
+
+// TLSConfig returns a server configuration using the certificate
+func (c *Certificate) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: c.GetCertificate, MinVersion: tls.VersionTLS12}
+}
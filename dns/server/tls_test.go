@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/stream"
+)
+
+// writeCertificate writes a new self-signed certificate for dns.test to certFile and keyFile and returns it in DER format
+func writeCertificate(t *testing.T, certFile, keyFile string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "dns.test"},
+		DNSNames:              []string{"dns.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestServer_ServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	der := writeCertificate(t, certFile, keyFile)
+	cert, err := LoadCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Handler: HandlerFunc(echoHandler), IdleTimeout: 2 * time.Second}
+	go s.ServeTLS(l, cert.TLSConfig()) //nolint: errcheck
+	t.Cleanup(func() { s.Close() })    //nolint: errcheck
+
+	roots := x509.NewCertPool()
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AddCert(parsed)
+	c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{ServerName: "dns.test", RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()                                //nolint: errcheck
+	c.SetDeadline(time.Now().Add(5 * time.Second)) //nolint: errcheck
+
+	tests := []struct {
+		name          string
+		opt           *record.OPT
+		wantRCode     uint8
+		wantKeepalive []byte
+	}{
+		{"Without EDNS", nil, dnserror.NoError, nil},
+		{"EDNS", &record.OPT{UDPSize: 1232}, dnserror.NoError, []byte{0, 20}},
+		{"Keepalive", &record.OPT{UDPSize: 1232, Options: []record.Option{{Code: record.OptionTCPKeepalive}}}, dnserror.NoError, []byte{0, 20}},
+		{"Keepalive with timeout", &record.OPT{UDPSize: 1232, Options: []record.Option{{Code: record.OptionTCPKeepalive, Data: []byte{0, 1}}}}, dnserror.FormatError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.A)))
+			if tt.opt != nil {
+				req.SetOPT(tt.opt)
+			}
+			if err := stream.Write(c, req.Encode()); err != nil {
+				t.Fatal(err)
+			}
+			data, err := stream.Read(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := message.Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Header.ID != req.Header.ID || resp.Header.ResponseCode() != tt.wantRCode {
+				t.Fatalf("response ID %X with RCode %d, want ID %X with RCode %d", resp.Header.ID, resp.Header.ResponseCode(), req.Header.ID, tt.wantRCode)
+			}
+			var keepalive []byte
+			if o := resp.OPT(); o != nil {
+				if k := o.Option(record.OptionTCPKeepalive); k != nil {
+					keepalive = k.Data
+				}
+			}
+			if !bytes.Equal(keepalive, tt.wantKeepalive) {
+				t.Errorf("edns-tcp-keepalive = %v, want %v", keepalive, tt.wantKeepalive)
+			}
+		})
+	}
+}
+
+func TestServer_ServeTLS_NoConfig(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Server{}).ServeTLS(l, nil); err != ErrNoTLSConfig {
+		t.Errorf("Server.ServeTLS() error = %v, want %v", err, ErrNoTLSConfig)
+	}
+}
+
+func TestServer_ServeUDP_Keepalive(t *testing.T) {
+	addr := startUDP(t, &Server{Handler: HandlerFunc(echoHandler)})
+	tests := []struct {
+		name      string
+		opt       *record.OPT
+		wantRCode uint8
+	}{
+		{"EDNS", &record.OPT{UDPSize: 1232}, dnserror.NoError},
+		{"Keepalive", &record.OPT{UDPSize: 1232, Options: []record.Option{{Code: record.OptionTCPKeepalive}}}, dnserror.NoError},
+		{"Keepalive with timeout", &record.OPT{UDPSize: 1232, Options: []record.Option{{Code: record.OptionTCPKeepalive, Data: []byte{0, 20}}}}, dnserror.NoError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.A)))
+			req.SetOPT(tt.opt)
+			resp := exchangeUDP(t, addr, req)
+			if resp.Header.ResponseCode() != tt.wantRCode {
+				t.Errorf("RCode = %d, want %d", resp.Header.ResponseCode(), tt.wantRCode)
+			}
+			if o := resp.OPT(); o != nil && o.Option(record.OptionTCPKeepalive) != nil {
+				t.Error("edns-tcp-keepalive was sent via UDP")
+			}
+		})
+	}
+}
+
+func TestCertificate_GetCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeCertificate(t, certFile, keyFile)
+	c, err := LoadCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged []error
+	c.Log = func(err error) { logged = append(logged, err) }
+	get := func() []byte {
+		c.mu.Lock()
+		c.checked = time.Time{}
+		c.mu.Unlock()
+		cert, err := c.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Certificate[0]
+	}
+	touch := func(d time.Duration) {
+		for _, f := range []string{certFile, keyFile} {
+			if err := os.Chtimes(f, time.Now().Add(d), time.Now().Add(d)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if !bytes.Equal(get(), first) || len(logged) != 0 {
+		t.Fatal("GetCertificate() reloaded unchanged files")
+	}
+	second := writeCertificate(t, certFile, keyFile)
+	touch(time.Minute)
+	if !bytes.Equal(get(), second) || len(logged) != 1 || logged[0] != nil {
+		t.Fatalf("GetCertificate() did not reload changed files, logged %v", logged)
+	}
+	if err := ioutil.WriteFile(keyFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(2 * time.Minute)
+	if !bytes.Equal(get(), second) || len(logged) != 2 || logged[1] == nil {
+		t.Fatalf("GetCertificate() did not keep the previous certificate, logged %v", logged)
+	}
+	if !bytes.Equal(get(), second) || len(logged) != 2 {
+		t.Errorf("GetCertificate() retried unchanged invalid files, logged %v", logged)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/message"
+)
+
+// ServeUDP reads requests from conn and answers each of them in a new goroutine. It always returns a non-nil error and closes conn.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close() //nolint: errcheck
+		return ErrServerClosed
+	}
+	s.packet = append(s.packet, conn)
+	s.mu.Unlock()
+	defer conn.Close() //nolint: errcheck
+
+	size := s.udpSize()
+	for {
+		buffer := make([]byte, size)
+		if s.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.ReadTimeout)) //nolint: errcheck
+		}
+		rlen, remote, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.serveUDP(&udpWriter{s, conn, remote, MinUDPSize}, buffer[:rlen])
+	}
+}
+
+// serveUDP answers a single UDP request. Responses are limited to the payload size the client is able to receive.
+func (s *Server) serveUDP(w *udpWriter, data []byte) {
+	req := s.parse(w, data)
+	if req == nil {
+		return
+	}
+	w.size = s.payloadSize(req)
+	s.dispatch(w, req)
+}
+
+// payloadSize returns the maximum size of a UDP response the client that sent req is able to receive.
+// This is 512 bytes unless the client advertised a larger size via EDNS which is then limited to the size of the server's buffer.
+func (s *Server) payloadSize(req *message.Message) int {
+	o := req.OPT()
+	if o == nil || int(o.UDPSize) <= MinUDPSize {
+		return MinUDPSize
+	}
+	if int(o.UDPSize) > s.udpSize() {
+		return s.udpSize()
+	}
+	return int(o.UDPSize)
+}
+
+// udpWriter sends responses to the client of a UDP request
+type udpWriter struct {
+	srv    *Server
+	conn   net.PacketConn
+	remote net.Addr
+	size   int
+}
+
+func (w *udpWriter) RemoteAddr() net.Addr {
+	return w.remote
+}
+
+func (w *udpWriter) Context() context.Context {
+	return context.Background()
+}
+
+func (w *udpWriter) WriteMsg(msg *message.Message) error {
+	if msg == nil {
+		return errors.New("cannot send empty message")
+	}
+	out := msg.Encode()
+	if len(out) > w.size {
+		msg.Truncate(w.size)
+		out = msg.Encode()
+	}
+	if w.srv.WriteTimeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.srv.WriteTimeout)) //nolint: errcheck
+	}
+	_, err := w.conn.WriteTo(out, w.remote)
+	if err != nil {
+		w.srv.logError(fmt.Errorf("failed to send response to %s: %s - retrying", w.remote.String(), err.Error()))
+		_, err = w.conn.WriteTo(out, w.remote)
+		if err != nil {
+			w.srv.logError(fmt.Errorf("failed to send response to %s on second attempt: %s - giving up", w.remote.String(), err.Error()))
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fossoreslp/go-dns/dns/error"
+	"github.com/fossoreslp/go-dns/dns/header"
+	"github.com/fossoreslp/go-dns/dns/label"
+	"github.com/fossoreslp/go-dns/dns/message"
+	"github.com/fossoreslp/go-dns/dns/query"
+	"github.com/fossoreslp/go-dns/dns/record-names"
+	"github.com/fossoreslp/go-dns/dns/record-types"
+	"github.com/fossoreslp/go-dns/dns/response"
+)
+
+func startUDP(t *testing.T, s *Server) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeUDP(conn)             //nolint: errcheck
+	t.Cleanup(func() { s.Close() }) //nolint: errcheck
+	return conn.LocalAddr().String()
+}
+
+// manyAnswers answers every request with n A records
+func manyAnswers(n int) HandlerFunc {
+	return func(w ResponseWriter, req *message.Message) {
+		rs := make([]response.Response, n)
+		for i := range rs {
+			rs[i] = response.New(req.Questions[0].Name, names.A, 60, []byte{10, 0, byte(i >> 8), byte(i)})
+		}
+		w.WriteMsg(message.New(header.NewAnswerHeader(req.Header.ID, true, false), req.Questions, rs, nil, nil)) //nolint: errcheck
+	}
+}
+
+func exchangeUDP(t *testing.T, addr string, req *message.Message) *message.Message {
+	limit := MinUDPSize
+	if o := req.OPT(); o != nil && int(o.UDPSize) > limit {
+		limit = int(o.UDPSize)
+	}
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()                                //nolint: errcheck
+	c.SetDeadline(time.Now().Add(5 * time.Second)) //nolint: errcheck
+	if _, err := c.Write(req.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 65535)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := message.Parse(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n > limit {
+		t.Errorf("response of %d bytes exceeds %d bytes", n, limit)
+	}
+	return resp
+}
+
+func TestServer_ServeUDP_RequestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{"Default", 0, DefaultRequestTimeout},
+		{"Configured", time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining := make(chan time.Duration, 1)
+			h := HandlerFunc(func(w ResponseWriter, req *message.Message) {
+				deadline, ok := w.Context().Deadline()
+				if !ok {
+					deadline = time.Time{}
+				}
+				remaining <- time.Until(deadline)
+				w.WriteMsg(dnserror.New(dnserror.NoError, false).Message(req.Header.ID, req.Questions...)) //nolint: errcheck
+			})
+			addr := startUDP(t, &Server{Handler: h, RequestTimeout: tt.timeout})
+			exchangeUDP(t, addr, message.New(header.NewQueryHeader(true), []query.Query{query.New(label.Label{"example", "com"}, names.QTYPE(names.A))}, nil, nil, nil))
+			if got := <-remaining; got <= 0 || got > tt.want {
+				t.Errorf("request context expires in %s, want at most %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_ServeUDP_Log(t *testing.T) {
+	logged := make(chan error, 1)
+	addr := startUDP(t, &Server{Handler: HandlerFunc(echoHandler), Log: func(err error) { logged <- err }})
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint: errcheck
+	if _, err := c.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-logged:
+		if !strings.Contains(err.Error(), "invalid request") {
+			t.Errorf("Server.Log() got %v, want an invalid request", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("invalid request was not logged")
+	}
+}
+
+func TestServer_ServeUDP_Truncation(t *testing.T) {
+	tests := []struct {
+		name        string
+		answers     int
+		wantAnswers int
+		wantTC      bool
+	}{
+		{"Fits", 5, 5, false},
+		{"Truncated", 100, 30, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startUDP(t, &Server{Handler: manyAnswers(tt.answers)})
+			resp := exchangeUDP(t, addr, newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.A))))
+			if got := resp.Header.Truncated(); got != tt.wantTC {
+				t.Errorf("TC = %v, want %v", got, tt.wantTC)
+			}
+			if got := len(resp.Answers); got != tt.wantAnswers {
+				t.Errorf("answers = %d, want %d", got, tt.wantAnswers)
+			}
+		})
+	}
+}
+
+func TestServer_ServeUDP_EDNS(t *testing.T) {
+	tests := []struct {
+		name        string
+		opt         *record.OPT
+		wantRCode   uint16
+		wantAnswers int
+		wantTC      bool
+		wantOPT     bool
+	}{
+		{"No EDNS", nil, 0, 30, true, false},
+		{"Payload below minimum", &record.OPT{UDPSize: 256}, 0, 29, true, true},
+		{"Large payload", &record.OPT{UDPSize: 1024}, 0, 60, false, true},
+		{"Payload above server limit", &record.OPT{UDPSize: 65000}, 0, 60, false, true},
+		{"Unsupported version", &record.OPT{UDPSize: 4096, Version: 1}, uint16(dnserror.BadVersion), 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startUDP(t, &Server{Handler: manyAnswers(60), UDPSize: 2048})
+			req := newRequest(query.New(label.Label{"example", "com"}, names.QTYPE(names.A)))
+			if tt.opt != nil {
+				req.SetOPT(tt.opt)
+			}
+			resp := exchangeUDP(t, addr, req)
+			if got := resp.ResponseCode(); got != tt.wantRCode {
+				t.Errorf("RCode = %d, want %d", got, tt.wantRCode)
+			}
+			if got := len(resp.Answers); got != tt.wantAnswers {
+				t.Errorf("answers = %d, want %d", got, tt.wantAnswers)
+			}
+			if got := resp.Header.Truncated(); got != tt.wantTC {
+				t.Errorf("TC = %v, want %v", got, tt.wantTC)
+			}
+			o := resp.OPT()
+			if (o != nil) != tt.wantOPT {
+				t.Fatalf("OPT present = %v, want %v", o != nil, tt.wantOPT)
+			}
+			if o != nil && (o.UDPSize != 2048 || o.Version != EDNSVersion) {
+				t.Errorf("OPT = %v, want UDP size 2048 and version %d", o, EDNSVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Read reads a single length prefixed DNS message from r as used by TCP and TLS connections (RFC 1035 section 4.2.2)
+func Read(r io.Reader) ([]byte, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint16(l[:])
+	if n == 0 {
+		return nil, errors.New("message length cannot be zero")
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Write writes msg to w prefixed by its length. Both are written at once so that concurrent writers on a connection do not interleave.
+func Write(w io.Writer, msg []byte) error {
+	if len(msg) > math.MaxUint16 {
+		return errors.New("message exceeds maximum length of 65535 bytes")
+	}
+	b := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(b[:2], uint16(len(msg)))
+	copy(b[2:], msg)
+	_, err := w.Write(b)
+	return err
+}
@@ -0,0 +1,60 @@
+package stream
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"Normal", []byte{0x00, 0x03, 0x01, 0x02, 0x03}, []byte{0x01, 0x02, 0x03}, false},
+		{"Trailing data", []byte{0x00, 0x01, 0x01, 0x02, 0x03}, []byte{0x01}, false},
+		{"Empty", []byte{}, nil, true},
+		{"Incomplete length", []byte{0x00}, nil, true},
+		{"Zero length", []byte{0x00, 0x00}, nil, true},
+		{"Message too short", []byte{0x00, 0x04, 0x01, 0x02}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(bytes.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"Normal", []byte{0x01, 0x02, 0x03}, []byte{0x00, 0x03, 0x01, 0x02, 0x03}, false},
+		{"Too long", make([]byte, 65536), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := Write(w, tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Write() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(w.Bytes(), tt.want) {
+				t.Errorf("Write() = %v, want %v", w.Bytes(), tt.want)
+			}
+		})
+	}
+}